@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/robherley/go-gameboy/pkg/apu"
+	"github.com/robherley/go-gameboy/pkg/audio"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+)
+
+// wavFile is a WAV file being recorded
+type wavFile struct {
+	*audio.WAVWriter
+	f    *os.File
+	path string
+}
+
+func createWAV(path string, rate int) (*wavFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create audio file: %w", err)
+	}
+
+	return &wavFile{WAVWriter: audio.NewWAVWriter(f, rate), f: f, path: path}, nil
+}
+
+func (w *wavFile) close() error {
+	if err := w.Close(); err != nil {
+		w.f.Close()
+		return fmt.Errorf("unable to write %s: %w", w.path, err)
+	}
+	return w.f.Close()
+}
+
+// checkSampleRate validates --sample-rate, before any files are created
+func checkSampleRate(rate int) error {
+	if rate != audio.Rate44100 && rate != audio.Rate48000 {
+		return fmt.Errorf("--sample-rate must be %d or %d", audio.Rate44100, audio.Rate48000)
+	}
+	return nil
+}
+
+// recordAudio writes the emulator's sound to a WAV file at path, and each channel to its own WAV
+// file in channelsDir. Either can be empty. The returned func finishes the files, only the first
+// call does anything so it can also be deferred
+func recordAudio(emu *emulator.Emulator, path, channelsDir string, rate int) (func() error, error) {
+	var files []*wavFile
+	removeAudio := func() {}
+	closeAll := func() error {
+		var err error
+		for _, f := range files {
+			if closeErr := f.close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}
+
+	// discard undoes a failed setup, so it doesn't leave empty recordings behind
+	var createdDir string
+	discard := func() {
+		// the emulator would otherwise keep writing to the closed files
+		removeAudio()
+		closeAll()
+		for _, f := range files {
+			os.Remove(f.path)
+		}
+		if createdDir != "" {
+			os.Remove(createdDir)
+		}
+	}
+
+	if path != "" {
+		wav, err := createWAV(path, rate)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, wav)
+		removeAudio = emu.AddAudio(wav, rate)
+	}
+
+	if channelsDir != "" {
+		if _, err := os.Stat(channelsDir); os.IsNotExist(err) {
+			createdDir = channelsDir
+		}
+		if err := os.MkdirAll(channelsDir, 0o755); err != nil {
+			discard()
+			return nil, fmt.Errorf("unable to create channels directory: %w", err)
+		}
+
+		var sinks [4]audio.SampleSink
+		for i := range sinks {
+			wav, err := createWAV(filepath.Join(channelsDir, apu.Channel(i).String()+".wav"), rate)
+			if err != nil {
+				discard()
+				return nil, err
+			}
+			files = append(files, wav)
+			sinks[i] = wav
+		}
+		emu.SetChannelAudio(sinks, rate)
+	}
+
+	finished := false
+	return func() error {
+		if finished {
+			return nil
+		}
+		finished = true
+
+		removeAudio()
+		emu.SetChannelAudio([4]audio.SampleSink{}, 0)
+		return closeAll()
+	}, nil
+}
+
+// parseChannels parses a comma separated list of channels, by number (1-4) or name
+func parseChannels(list string) ([]apu.Channel, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	var channels []apu.Channel
+	for _, field := range strings.Split(list, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		found := false
+		for c := apu.Square1; c <= apu.Noise; c++ {
+			if field == c.String() || field == strconv.Itoa(int(c)+1) {
+				channels = append(channels, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown channel %q, must be 1-4 or one of square1, square2, wave, noise", field)
+		}
+	}
+
+	return channels, nil
+}
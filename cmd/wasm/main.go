@@ -0,0 +1,146 @@
+//go:build js && wasm
+
+// Command wasm runs the emulator in a browser. It's built with GOOS=js GOARCH=wasm and adds these
+// functions to the page, it's up to JavaScript to call runFrame ~59.73 times a second:
+//
+//	loadROM(rom: Uint8Array, sampleRate = 48000): string | null
+//	runFrame(): string | null
+//	getFramebuffer(): Uint8Array          // 160x144 RGBA, for ImageData
+//	setButtons(buttons: number)           // bits are Right, Left, Up, Down, A, B, Select, Start
+//	getAudioSamples(): Float32Array       // interleaved stereo since the last call
+//
+// loadROM and runFrame return an error message, or null if they succeeded.
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"syscall/js"
+
+	"github.com/robherley/go-gameboy/pkg/apu"
+	"github.com/robherley/go-gameboy/pkg/audio"
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+)
+
+// maxQueuedSeconds of sound are kept when JavaScript doesn't collect it, older samples are dropped
+const maxQueuedSeconds = 1
+
+type bridge struct {
+	emu *emulator.Emulator
+	// samples are queued until getAudioSamples is called
+	samples []apu.Sample
+	rate    int
+	rgba    []byte
+	// bytes is the scratch space samples are converted in
+	bytes []byte
+}
+
+func main() {
+	b := &bridge{rgba: make([]byte, ppu.Width*ppu.Height*4)}
+
+	global := js.Global()
+	global.Set("loadROM", js.FuncOf(b.loadROM))
+	global.Set("runFrame", js.FuncOf(b.runFrame))
+	global.Set("getFramebuffer", js.FuncOf(b.getFramebuffer))
+	global.Set("setButtons", js.FuncOf(b.setButtons))
+	global.Set("getAudioSamples", js.FuncOf(b.getAudioSamples))
+
+	// the functions are called from JavaScript after main returns, so it never does
+	select {}
+}
+
+func (b *bridge) loadROM(this js.Value, args []js.Value) any {
+	if len(args) < 1 || args[0].Type() != js.TypeObject {
+		return "loadROM expects the rom as a Uint8Array"
+	}
+
+	rate := audio.Rate48000
+	if len(args) > 1 && args[1].Type() == js.TypeNumber {
+		rate = args[1].Int()
+	}
+	if rate <= 0 {
+		return "sample rate must be positive"
+	}
+
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+
+	cart, err := cartridge.FromBytes(data)
+	if err != nil {
+		return err.Error()
+	}
+	if !cart.Supported() {
+		js.Global().Get("console").Call("warn", fmt.Sprintf("%s cartridges aren't supported, the game will likely crash once it switches banks", cart.CartridgeType()))
+	}
+
+	b.emu = emulator.New(cart)
+	b.rate = rate
+	b.samples = b.samples[:0]
+	b.emu.AddAudio(audio.SinkFunc(b.queue), rate)
+	return nil
+}
+
+func (b *bridge) queue(samples []apu.Sample) error {
+	b.samples = append(b.samples, samples...)
+	if over := len(b.samples) - b.rate*maxQueuedSeconds; over > 0 {
+		b.samples = append(b.samples[:0], b.samples[over:]...)
+	}
+	return nil
+}
+
+func (b *bridge) runFrame(this js.Value, args []js.Value) any {
+	if b.emu == nil {
+		return "no rom is loaded"
+	}
+
+	if err := b.emu.RunFrame(); err != nil {
+		return err.Error()
+	}
+	return nil
+}
+
+func (b *bridge) getFramebuffer(this js.Value, args []js.Value) any {
+	if b.emu != nil {
+		for i, shade := range b.emu.Framebuffer() {
+			c := ppu.PaletteGray[shade]
+			b.rgba[i*4], b.rgba[i*4+1], b.rgba[i*4+2], b.rgba[i*4+3] = c.R, c.G, c.B, c.A
+		}
+	}
+
+	buf := js.Global().Get("Uint8Array").New(len(b.rgba))
+	js.CopyBytesToJS(buf, b.rgba)
+	return buf
+}
+
+func (b *bridge) setButtons(this js.Value, args []js.Value) any {
+	if b.emu != nil && len(args) > 0 && args[0].Type() == js.TypeNumber {
+		if pressed := joypad.Button(args[0].Int()); pressed != b.emu.Joypad.Pressed() {
+			b.emu.Joypad.SetPressed(pressed)
+		}
+	}
+	return nil
+}
+
+// getAudioSamples copies the samples as little endian floats, JavaScript views them as a
+// Float32Array since values can only be copied to it one at a time
+func (b *bridge) getAudioSamples(this js.Value, args []js.Value) any {
+	n := len(b.samples) * 2
+	if cap(b.bytes) < n*4 {
+		b.bytes = make([]byte, n*4)
+	}
+	bytes := b.bytes[:n*4]
+
+	for i, s := range b.samples {
+		binary.LittleEndian.PutUint32(bytes[i*8:], math.Float32bits(s.Left))
+		binary.LittleEndian.PutUint32(bytes[i*8+4:], math.Float32bits(s.Right))
+	}
+	b.samples = b.samples[:0]
+
+	buf := js.Global().Get("Uint8Array").New(len(bytes))
+	js.CopyBytesToJS(buf, bytes)
+	return js.Global().Get("Float32Array").New(buf.Get("buffer"))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/robherley/go-gameboy/pkg/disasm"
+)
+
+func disasmCommand(args []string) error {
+	fs := newFlagSet("disasm", "<path-to-rom>")
+	bank := fs.Int("bank", 1, "ROM bank to disassemble, switched into 0x4000-0x7FFF unless 0")
+	recursive := fs.Bool("recursive", false, "follow JP/CALL/JR targets from the entry point and interrupt vectors to separate code from data")
+	from := addressFlag(0x0000)
+	to := addressFlag(0x8000)
+	fs.Var(&from, "from", "start address")
+	fs.Var(&to, "to", "end address (exclusive)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	cart, err := cartridge.FromFile(positional[0])
+	if err != nil {
+		return err
+	}
+
+	if *bank < 0 || *bank >= cart.ROMBanks() {
+		return fmt.Errorf("invalid bank %d, rom has banks 0-%d", *bank, cart.ROMBanks()-1)
+	}
+
+	// with only a bank given, show that bank's window
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	if explicit["bank"] && !explicit["from"] && !explicit["to"] {
+		start, end := disasm.BankWindow(*bank)
+		from, to = addressFlag(start), addressFlag(end)
+	}
+
+	banks := []int{0, *bank}
+	if *bank == 0 {
+		banks = banks[:1]
+	}
+
+	var code map[disasm.Location]disasm.Line
+	if *recursive {
+		code = disasm.Trace(cart)
+	}
+
+	// ranges may span bank 0 and the switchable bank
+	for _, b := range banks {
+		start, end := disasm.BankWindow(b)
+		lo, hi := uint16(from), uint16(to)
+		if lo < start {
+			lo = start
+		}
+		if hi > end {
+			hi = end
+		}
+		if lo >= hi {
+			continue
+		}
+
+		var lines []disasm.Line
+		if *recursive {
+			lines = disasm.Listing(cart, b, lo, hi, code)
+		} else {
+			lines = disasm.Range(cart, b, lo, hi)
+		}
+
+		for _, line := range lines {
+			fmt.Fprintln(os.Stdout, line.String())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+	"github.com/robherley/go-gameboy/pkg/tui"
+)
+
+// frontend shows the emulator and feeds it input until ctx is done, the user quits or it faults
+type frontend func(ctx context.Context, emu *emulator.Emulator, opts frontendOptions) error
+
+// frontends can be picked with --ui
+var frontends = map[string]frontend{
+	"sdl":  runSDL,
+	"term": runTerminal,
+	"none": runHeadless,
+}
+
+type frontendOptions struct {
+	palette ppu.Palette
+	// bindingsPath is the file keys and controller buttons are loaded from, if it's empty the
+	// frontend looks in its default place
+	bindingsPath string
+}
+
+func frontendNames() []string {
+	return []string{"sdl", "term", "none"}
+}
+
+func frontendByName(name string) (frontend, error) {
+	run, ok := frontends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown --ui %q, must be one of %s", name, strings.Join(frontendNames(), ", "))
+	}
+
+	return run, nil
+}
+
+// runTerminal draws in the terminal, for playing and debugging over SSH
+func runTerminal(ctx context.Context, emu *emulator.Emulator, opts frontendOptions) error {
+	return tui.New(emu, os.Stdin, os.Stdout, tui.Options{Palette: opts.palette}).Run(ctx)
+}
+
+// runHeadless runs without a window, for test roms and recording
+func runHeadless(ctx context.Context, emu *emulator.Emulator, opts frontendOptions) error {
+	return emu.Run(ctx)
+}
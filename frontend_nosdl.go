@@ -0,0 +1,15 @@
+//go:build nosdl
+
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+)
+
+// runSDL is unavailable in builds without cgo and the SDL2 libraries
+func runSDL(ctx context.Context, emu *emulator.Emulator, opts frontendOptions) error {
+	return errors.New("built without SDL, use --ui none")
+}
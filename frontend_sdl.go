@@ -0,0 +1,31 @@
+//go:build !nosdl
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/ui"
+)
+
+func runSDL(ctx context.Context, emu *emulator.Emulator, opts frontendOptions) error {
+	bindings, err := ui.LoadBindings(opts.bindingsPath)
+	if err != nil {
+		return err
+	}
+
+	window, err := ui.NewSDL(emu, ui.Options{Palette: opts.palette, Bindings: &bindings})
+	if err != nil {
+		return err
+	}
+	defer window.Close()
+
+	if err := window.AudioError(); err != nil {
+		fmt.Fprintf(os.Stderr, "no sound: %v\n", err)
+	}
+
+	return window.Run(ctx)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/robherley/go-gameboy/pkg/audio"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/gbs"
+)
+
+func gbsCommand(args []string) error {
+	fs := newFlagSet("gbs", "<path-to-gbs>")
+	track := fs.Int("track", 0, "`track` to play from 1, the file's default if not set")
+	seconds := fs.Float64("seconds", 120, "length of the recording in `seconds`")
+	out := fs.String("out", "track.wav", "write the track to a WAV `file`")
+	sampleRate := fs.Int("sample-rate", audio.Rate48000, fmt.Sprintf("sample `rate` of the recording, %d or %d", audio.Rate44100, audio.Rate48000))
+	mute := fs.String("mute", "", "comma separated `channels` to leave out (1-4, or square1, square2, wave, noise)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	if *seconds <= 0 {
+		return fmt.Errorf("--seconds must be positive")
+	}
+
+	if err := checkSampleRate(*sampleRate); err != nil {
+		return err
+	}
+
+	muted, err := parseChannels(*mute)
+	if err != nil {
+		return err
+	}
+
+	file, err := gbs.FromFile(positional[0])
+	if err != nil {
+		return err
+	}
+
+	if *track == 0 {
+		*track = file.DefaultTrack()
+	}
+
+	cart, err := file.Cartridge(*track)
+	if err != nil {
+		return err
+	}
+
+	info := []string{}
+	for _, field := range []string{file.TitleString(), file.AuthorString(), file.CopyrightString()} {
+		if field != "" {
+			info = append(info, field)
+		}
+	}
+	fmt.Println(strings.Join(info, " - "))
+	fmt.Printf("track %d of %d, %gs to %s\n", *track, file.Songs, *seconds, *out)
+
+	emu := emulator.New(cart)
+	for _, c := range muted {
+		emu.APU.SetMuted(c, true)
+	}
+
+	finishAudio, err := recordAudio(emu, *out, "", *sampleRate)
+	if err != nil {
+		return err
+	}
+	defer finishAudio()
+
+	// stop early if interrupted, keeping what was recorded
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := emu.RunFrames(ctx, int(math.Ceil(*seconds*emulator.FrameRate))); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	return finishAudio()
+}
@@ -3,9 +3,11 @@ module github.com/robherley/go-gameboy
 go 1.18
 
 require (
+	github.com/gorilla/websocket v1.5.0
 	github.com/pterm/pterm v0.12.38
 	github.com/stretchr/testify v1.7.1
 	github.com/veandco/go-sdl2 v0.4.19
+	golang.org/x/term v0.10.0
 )
 
 require (
@@ -16,7 +18,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
 	github.com/xo/terminfo v0.0.0-20210125001918-ca9a967f8778 // indirect
-	golang.org/x/sys v0.0.0-20220408201424-a24fb2fb8a0f // indirect
-	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211 // indirect
+	golang.org/x/sys v0.10.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
@@ -2,9 +2,6 @@ package debug
 
 import (
 	"fmt"
-	"reflect"
-	"runtime"
-	"strings"
 
 	"github.com/robherley/go-gameboy/pkg/cartridge"
 	"github.com/robherley/go-gameboy/pkg/cpu"
@@ -65,7 +62,7 @@ func Instruction(pc, sp uint16, opcode byte, in *cpu.Instruction) {
 		return
 	}
 
-	instructionStr := fmt.Sprintf("%s ", operationName(in.Operation))
+	instructionStr := fmt.Sprintf("%s ", in.Mnemonic())
 
 	if in.Operands != nil {
 		for i, op := range in.Operands {
@@ -96,11 +93,3 @@ func Instruction(pc, sp uint16, opcode byte, in *cpu.Instruction) {
 
 	fmt.Printf(" %-14s", instructionStr)
 }
-
-func operationName(op cpu.Operation) string {
-	// not the most efficient, but good enough for debugging rn
-	// TODO: add mnemonic to instruction as str const & see if effect perf
-	funcName := runtime.FuncForPC(reflect.ValueOf(op).Pointer()).Name()
-	split := strings.Split(funcName, ".")
-	return split[len(split)-1]
-}
@@ -0,0 +1,37 @@
+// Package testutil builds the small cartridges and emulators the tests run programs on
+package testutil
+
+import (
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+)
+
+// ROM returns a 32KiB rom only image with the program at the entry point, the header can be
+// changed before loading it
+func ROM(program ...byte) []byte {
+	rom := make([]byte, 2*cartridge.ROMBankSize)
+	copy(rom[0x100:], program)
+	return rom
+}
+
+// Cartridge loads a rom only cartridge with the program at the entry point
+func Cartridge(t testing.TB, program ...byte) *cartridge.Cartridge {
+	t.Helper()
+
+	cart, err := cartridge.FromBytes(ROM(program...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cart
+}
+
+// Emulator creates an unthrottled emulator for a rom only cartridge with the program at the entry point
+func Emulator(t testing.TB, program ...byte) *emulator.Emulator {
+	t.Helper()
+
+	emu := emulator.New(Cartridge(t, program...))
+	emu.Pacer.SetUnthrottled(true)
+	return emu
+}
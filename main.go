@@ -1,27 +1,362 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 
+	"github.com/robherley/go-gameboy/pkg/audio"
 	"github.com/robherley/go-gameboy/pkg/cartridge"
 	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/ppu"
 )
 
+// command is a subcommand, invoked with the arguments after its name
+type command func(args []string) error
+
+var commands = map[string]command{
+	"disasm":     disasmCommand,
+	"test":       testCommand,
+	"screenshot": screenshotCommand,
+	"gbs":        gbsCommand,
+	"serve":      serveCommand,
+}
+
+// errUsage indicates the command was invoked incorrectly, the usage has already been printed
+var errUsage = errors.New("usage")
+
 func main() {
-	if len(os.Args[1:]) != 1 {
-		fmt.Fprintf(os.Stderr, "usage: %s <path-to-rom>\n", filepath.Base(os.Args[0]))
+	args := os.Args[1:]
+
+	run := runCommand
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			run, args = cmd, args[1:]
+		}
+	}
+
+	if err := run(args); err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", filepath.Base(os.Args[0]), err)
 		os.Exit(1)
 	}
+}
+
+func runCommand(args []string) (err error) {
+	fs := newFlagSet("", "<path-to-rom>")
+	tracePath := fs.String("trace", "", "write a gameboy-doctor compatible log of every instruction to `file`")
+	fakeLY := fs.Bool("fake-ly", false, "always read LY as 0x90 while tracing, as gameboy-doctor expects")
+	speed := fs.Float64("speed", 1, fmt.Sprintf("emulation speed `multiplier`, from %gx to %gx", emulator.MinSpeed, emulator.MaxSpeed))
+	unthrottled := fs.Bool("unthrottled", false, "run as fast as possible")
+	loadSlot := fs.String("load-state", "", "load the save state in `slot` (0-9, or a file path) on start, BESS states from other emulators are detected")
+	saveSlot := fs.String("save-state", "", "save the state to `slot` (0-9, or a file path) on exit")
+	bess := fs.Bool("bess", false, "save states in the BESS format, for other emulators")
+	recordMovie := fs.String("record-movie", "", "record the input from power on to a movie `file`")
+	playMovie := fs.String("play-movie", "", "play back a movie `file`, stopping if it desyncs")
+	dumpDir := fs.String("dump-frames", "", "write every presented frame to `dir` as numbered PNGs")
+	frontendName := fs.String("ui", "sdl", fmt.Sprintf("`frontend` to play in (%s), none runs headless", strings.Join(frontendNames(), ", ")))
+	bindingsPath := fs.String("bindings", "", "load keyboard and controller bindings from a JSON `file`, instead of the config directory")
+	palette := fs.String("palette", "gray", fmt.Sprintf("colors to draw the screen and dumped frames with (%s)", strings.Join(ppu.PaletteNames(), ", ")))
+	scale := fs.Int("scale", 1, "integer `factor` to scale dumped frames up by")
+	recordAudioPath := fs.String("record-audio", "", "record the sound to a WAV `file`")
+	recordChannels := fs.String("record-channels", "", "record each sound channel to its own WAV file in `dir`")
+	mute := fs.String("mute", "", "comma separated `channels` to leave out of the sound (1-4, or square1, square2, wave, noise)")
+	sampleRate := fs.Int("sample-rate", audio.Rate48000, fmt.Sprintf("sample `rate` of recorded sound, %d or %d", audio.Rate44100, audio.Rate48000))
+	strict := fs.Bool("strict", false, "fail on illegal opcodes and reserved memory access, instead of behaving like the hardware")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	if *fakeLY && *tracePath == "" {
+		return errors.New("--fake-ly can only be used with --trace")
+	}
+
+	if *speed < emulator.MinSpeed || *speed > emulator.MaxSpeed {
+		return fmt.Errorf("--speed must be between %g and %g", emulator.MinSpeed, emulator.MaxSpeed)
+	}
+
+	loadPath, err := statePath(positional[0], *loadSlot)
+	if err != nil {
+		return fmt.Errorf("--load-state: %w", err)
+	}
+
+	savePath, err := statePath(positional[0], *saveSlot)
+	if err != nil {
+		return fmt.Errorf("--save-state: %w", err)
+	}
+
+	if *recordMovie != "" && *playMovie != "" {
+		return errors.New("--record-movie and --play-movie can't be used together")
+	}
+
+	if loadPath != "" && (*recordMovie != "" || *playMovie != "") {
+		return errors.New("movies start from power on, they can't be used with --load-state")
+	}
+
+	if err := checkSampleRate(*sampleRate); err != nil {
+		return err
+	}
+
+	muted, err := parseChannels(*mute)
+	if err != nil {
+		return err
+	}
+
+	runUI, err := frontendByName(*frontendName)
+	if err != nil {
+		return err
+	}
+
+	dumper, err := newFrameDumper(*dumpDir, *palette, *scale)
+	if err != nil {
+		return err
+	}
 
-	cart, err := cartridge.FromFile(os.Args[1])
+	cart, err := openCartridge(positional[0])
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	// debug.Cart(cart)
 
+	// battery backed ram is loaded from next to the rom, and written back however the emulator stops.
+	// Movies always start from blank ram and leave the save alone, otherwise recording would change
+	// the save the movie has to be played back with
+	if cart.CartridgeType().HasBattery() && *recordMovie == "" && *playMovie == "" {
+		path := batteryPath(positional[0])
+		if err := cart.LoadRAM(path); err != nil {
+			return err
+		}
+		defer func() {
+			if saveErr := cart.SaveRAM(path); err == nil {
+				err = saveErr
+			}
+		}()
+	}
+
 	emu := emulator.New(cart)
-	emu.Boot()
+	// test roms report their results over serial. It goes to stdout when headless so it can be piped,
+	// the terminal frontend draws over stdout so it's dropped there
+	switch *frontendName {
+	case "none":
+		emu.MMU.SetSerialOutput(os.Stdout)
+	case "sdl":
+		emu.MMU.SetSerialOutput(os.Stderr)
+	}
+	emu.Pacer.SetSpeed(*speed)
+	emu.Pacer.SetUnthrottled(*unthrottled)
+	if *strict {
+		emu.SetPolicy(emulator.PolicyStrict)
+	}
+
+	for _, c := range muted {
+		emu.APU.SetMuted(c, true)
+	}
+
+	if loadPath != "" {
+		if err := loadState(emu, loadPath); err != nil {
+			return err
+		}
+	}
+
+	finishMovie, err := startMovie(emu, *recordMovie, *playMovie)
+	if err != nil {
+		return err
+	}
+
+	// everything has been checked, the outputs can be created
+	if err := dumper.attach(emu); err != nil {
+		return err
+	}
+
+	finishAudio, err := recordAudio(emu, *recordAudioPath, *recordChannels, *sampleRate)
+	if err != nil {
+		return err
+	}
+	defer finishAudio()
+
+	// run until interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *tracePath != "" {
+		f, err := os.Create(*tracePath)
+		if err != nil {
+			return fmt.Errorf("unable to create trace file: %w", err)
+		}
+		defer f.Close()
+
+		tracer := emulator.NewDoctorTracer(f)
+		emu.Trace(tracer, *fakeLY)
+		defer tracer.Flush()
+	}
+
+	err = runUI(ctx, emu, frontendOptions{palette: dumper.palette, bindingsPath: *bindingsPath})
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	if err == nil {
+		err = dumper.err
+	}
+	if audioErr := finishAudio(); err == nil {
+		err = audioErr
+	}
+
+	if err := finishMovie(err); err != nil {
+		return err
+	}
+
+	if savePath != "" {
+		return saveState(emu, savePath, *bess)
+	}
+
+	return nil
+}
+
+// openCartridge loads the rom at path, warning if its memory bank controller isn't emulated
+func openCartridge(path string) (*cartridge.Cartridge, error) {
+	cart, err := cartridge.FromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cart.Supported() {
+		fmt.Fprintf(os.Stderr, "warning: %s cartridges aren't supported, the game will likely crash once it switches banks\n", cart.CartridgeType())
+	}
+
+	return cart, nil
+}
+
+// batteryPath is where a rom's battery backed ram is kept: game.gb -> game.sav
+func batteryPath(romPath string) string {
+	return strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".sav"
+}
+
+// statePath resolves a save state slot, numbered slots are kept next to the rom: game.gb -> game.ss1.
+// Files need to look like a path (with a directory or an extension), so a mistyped slot like 10
+// isn't quietly saved to a file named 10
+func statePath(romPath string, slot string) (string, error) {
+	if slot == "" {
+		return "", nil
+	}
+
+	if len(slot) == 1 && slot[0] >= '0' && slot[0] <= '9' {
+		return fmt.Sprintf("%s.ss%s", strings.TrimSuffix(romPath, filepath.Ext(romPath)), slot), nil
+	}
+
+	if filepath.Base(slot) != slot || filepath.Ext(slot) != "" {
+		return slot, nil
+	}
+
+	return "", fmt.Errorf("slot must be 0-9 or a file path (ie: ./%s or %s.ss)", slot, slot)
+}
+
+func loadState(emu *emulator.Emulator, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to open save state: %w", err)
+	}
+
+	load := emu.LoadState
+	if emulator.IsBESS(data) {
+		load = emu.ImportBESS
+	}
+
+	if err := load(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("unable to load %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func saveState(emu *emulator.Emulator, path string, bess bool) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create save state: %w", err)
+	}
+
+	save := emu.SaveState
+	if bess {
+		save = emu.ExportBESS
+	}
+
+	if err := save(f); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to save %s: %w", path, err)
+	}
+
+	return f.Close()
+}
+
+// newFlagSet creates a flag set for a subcommand, with usage describing the positional arguments
+func newFlagSet(name, positional string) *flag.FlagSet {
+	prog := filepath.Base(os.Args[0])
+	if name != "" {
+		prog += " " + name
+	}
+
+	fs := flag.NewFlagSet(prog, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [flags] %s\n", prog, positional)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parseFlags parses flags that can be interspersed with positional arguments, ie: rom.gb --bank 2
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, errUsage
+			}
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// addressFlag is a 16-bit address flag, accepting hex (0x150), octal or decimal
+type addressFlag uint16
+
+func (a *addressFlag) String() string {
+	return fmt.Sprintf("0x%04X", uint16(*a))
+}
+
+func (a *addressFlag) Set(s string) error {
+	var val uint64
+	if _, err := fmt.Sscan(s, &val); err != nil {
+		return err
+	}
+	if val > 0xFFFF {
+		return fmt.Errorf("address out of range: %s", s)
+	}
+
+	*a = addressFlag(val)
+	return nil
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/movie"
+)
+
+// startMovie records to or plays back from a movie file, if either is set. The returned func
+// handles the error the emulator stopped with, saving the recording
+func startMovie(emu *emulator.Emulator, recordPath, playPath string) (func(error) error, error) {
+	switch {
+	case recordPath != "":
+		rec, err := movie.Record(emu)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(runErr error) error {
+			if err := writeMovie(rec.Movie(), recordPath); err != nil {
+				return err
+			}
+			return runErr
+		}, nil
+	case playPath != "":
+		f, err := os.Open(playPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open movie: %w", err)
+		}
+		defer f.Close()
+
+		m, err := movie.Read(f)
+		if err != nil {
+			return nil, err
+		}
+
+		player, err := movie.Play(emu, m, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(runErr error) error {
+			if errors.Is(runErr, movie.ErrorEnded) {
+				fmt.Fprintf(os.Stderr, "movie ended after %d frames, in sync at all %d checkpoints\n", player.Frame(), len(m.Checkpoints))
+				return nil
+			}
+			return runErr
+		}, nil
+	default:
+		return func(runErr error) error { return runErr }, nil
+	}
+}
+
+func writeMovie(m *movie.Movie, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create movie: %w", err)
+	}
+
+	if _, err := m.WriteTo(f); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write movie: %w", err)
+	}
+
+	return f.Close()
+}
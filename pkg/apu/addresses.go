@@ -0,0 +1,33 @@
+package apu
+
+const (
+	// Channel 1, square with sweep
+	NR10_ADDRESS uint16 = 0xFF10
+	NR11_ADDRESS uint16 = 0xFF11
+	NR12_ADDRESS uint16 = 0xFF12
+	NR13_ADDRESS uint16 = 0xFF13
+	NR14_ADDRESS uint16 = 0xFF14
+	// Channel 2, square
+	NR21_ADDRESS uint16 = 0xFF16
+	NR22_ADDRESS uint16 = 0xFF17
+	NR23_ADDRESS uint16 = 0xFF18
+	NR24_ADDRESS uint16 = 0xFF19
+	// Channel 3, wave
+	NR30_ADDRESS uint16 = 0xFF1A
+	NR31_ADDRESS uint16 = 0xFF1B
+	NR32_ADDRESS uint16 = 0xFF1C
+	NR33_ADDRESS uint16 = 0xFF1D
+	NR34_ADDRESS uint16 = 0xFF1E
+	// Channel 4, noise
+	NR41_ADDRESS uint16 = 0xFF20
+	NR42_ADDRESS uint16 = 0xFF21
+	NR43_ADDRESS uint16 = 0xFF22
+	NR44_ADDRESS uint16 = 0xFF23
+	// Master volume, panning and power
+	NR50_ADDRESS uint16 = 0xFF24
+	NR51_ADDRESS uint16 = 0xFF25
+	NR52_ADDRESS uint16 = 0xFF26
+
+	WAVE_RAM_OFFSET uint16 = 0xFF30
+	WAVE_RAM_SIZE          = 0x10
+)
@@ -0,0 +1,474 @@
+package apu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	errs "github.com/robherley/go-gameboy/pkg/errors"
+)
+
+// https://gbdev.io/pandocs/Audio.html
+
+// SampleRate is the rate Output changes at, once per M-cycle
+const SampleRate = 4194304 / 4
+
+// registers FF10-FF26
+const registerCount = 0x17
+
+// bits that always read as 1 for each register, unused registers read as 0xFF
+// https://gbdev.io/pandocs/Audio_Registers.html
+var readMasks = [registerCount]byte{
+	0x80, 0x3F, 0x00, 0xFF, 0xBF, // NR10-NR14
+	0xFF, 0x3F, 0x00, 0xFF, 0xBF, // NR20-NR24
+	0x7F, 0xFF, 0x9F, 0xFF, 0xBF, // NR30-NR34
+	0xFF, 0xFF, 0x00, 0x00, 0xBF, // NR40-NR44
+	0x00, 0x00, 0x70, // NR50-NR52
+}
+
+// NR52 bit 7 turns the APU on and off
+const power = 1 << 7
+
+// the high pass filter's capacitor keeps this much of its charge every M-cycle, 0.999958^4
+const charge = 0.999832
+
+// Channel is one of the four sound channels
+type Channel int
+
+const (
+	Square1 Channel = iota
+	Square2
+	Wave
+	Noise
+)
+
+var channelNames = [4]string{"square1", "square2", "wave", "noise"}
+
+func (c Channel) String() string {
+	if c < 0 || int(c) >= len(channelNames) {
+		return fmt.Sprintf("Channel(%d)", int(c))
+	}
+	return channelNames[c]
+}
+
+// Sample is a stereo sample, each side from -1 to 1
+type Sample struct {
+	Left, Right float32
+}
+
+type APU struct {
+	registers [registerCount]byte
+	square1   square
+	square2   square
+	wave      wave
+	noise     noise
+	// step of the frame sequencer, clocked at 512 Hz by DIV
+	frameStep byte
+	// the output goes through a high pass filter that removes the DC offset of the DACs
+	capacitors [2]float32
+	sample     Sample
+	// OnSample is called with every sample, at SampleRate
+	OnSample func(Sample)
+	// muted channels are left out of the mix
+	muted [4]bool
+	// OnChannelSamples is called with every channel's own sample, at SampleRate. Each channel has
+	// its own filter, and they're included even when muted
+	OnChannelSamples  func([4]Sample)
+	channelCapacitors [4][2]float32
+}
+
+func New() *APU {
+	a := &APU{}
+
+	// post boot rom values, the boot sound has finished but channel 1 is still on
+	copy(a.registers[:], []byte{
+		0x80, 0xBF, 0xF3, 0xFF, 0xBF,
+		0x00, 0x3F, 0x00, 0xFF, 0xBF,
+		0x7F, 0xFF, 0x9F, 0xFF, 0xBF,
+		0x00, 0xFF, 0x00, 0x00, 0xBF,
+		0x77, 0xF3, 0xF1,
+	})
+	a.square1.Enabled = true
+
+	return a
+}
+
+func (a *APU) reg(address uint16) byte {
+	return a.registers[address-NR10_ADDRESS]
+}
+
+func (a *APU) powered() bool {
+	return a.reg(NR52_ADDRESS)&power != 0
+}
+
+// frequency is the 11 bit frequency split across the lower byte and bits 2-0 of the control register
+func (a *APU) frequency(lo, hi uint16) uint16 {
+	return uint16(a.reg(hi)&0b111)<<8 | uint16(a.reg(lo))
+}
+
+func (a *APU) setFrequency(lo, hi uint16, freq uint16) {
+	a.registers[lo-NR10_ADDRESS] = byte(freq)
+	a.registers[hi-NR10_ADDRESS] = a.reg(hi)&^0b111 | byte(freq>>8)&0b111
+}
+
+// Tick advances the APU by one M-cycle
+func (a *APU) Tick() {
+	if a.powered() {
+		for i := 0; i < 4; i++ {
+			a.square1.tick(a.frequency(NR13_ADDRESS, NR14_ADDRESS))
+			a.square2.tick(a.frequency(NR23_ADDRESS, NR24_ADDRESS))
+			a.wave.tick(a.frequency(NR33_ADDRESS, NR34_ADDRESS))
+			a.noise.tick(a.reg(NR43_ADDRESS))
+		}
+	}
+
+	a.mix()
+}
+
+// ClockFrameSequencer advances the frame sequencer, which clocks the length timers at 256 Hz, the
+// sweep at 128 Hz and the envelopes at 64 Hz. It's called when bit 4 of DIV falls
+// https://gbdev.io/pandocs/Audio_details.html#div-apu
+func (a *APU) ClockFrameSequencer() {
+	if !a.powered() {
+		return
+	}
+
+	step := a.frameStep
+	a.frameStep = (a.frameStep + 1) & 7
+
+	if step%2 == 0 {
+		if a.square1.Length.clock() {
+			a.square1.Enabled = false
+		}
+		if a.square2.Length.clock() {
+			a.square2.Enabled = false
+		}
+		if a.wave.Length.clock() {
+			a.wave.Enabled = false
+		}
+		if a.noise.Length.clock() {
+			a.noise.Enabled = false
+		}
+	}
+
+	if step == 2 || step == 6 {
+		a.clockSweep()
+	}
+
+	if step == 7 {
+		a.square1.Envelope.clock(a.reg(NR12_ADDRESS))
+		a.square2.Envelope.clock(a.reg(NR22_ADDRESS))
+		a.noise.Envelope.clock(a.reg(NR42_ADDRESS))
+	}
+}
+
+func (a *APU) clockSweep() {
+	s := &a.square1.Sweep
+	nr10 := a.reg(NR10_ADDRESS)
+
+	if s.Timer > 0 {
+		s.Timer--
+	}
+	if s.Timer > 0 {
+		return
+	}
+	s.reload(nr10)
+
+	if !s.Enabled || sweepPace(nr10) == 0 {
+		return
+	}
+
+	freq := s.next(nr10)
+	if freq > 2047 {
+		a.square1.Enabled = false
+		return
+	}
+
+	if nr10&0b111 != 0 {
+		s.Shadow = freq
+		a.setFrequency(NR13_ADDRESS, NR14_ADDRESS, freq)
+
+		// the next frequency is checked for overflow straight away
+		if s.next(nr10) > 2047 {
+			a.square1.Enabled = false
+		}
+	}
+}
+
+// Channels returns the digital output of each channel, 0-15
+func (a *APU) Channels() [4]byte {
+	return [4]byte{
+		a.square1.output(a.reg(NR11_ADDRESS)),
+		a.square2.output(a.reg(NR21_ADDRESS)),
+		a.wave.output(a.reg(NR32_ADDRESS)),
+		a.noise.output(),
+	}
+}
+
+// dacs returns which of the channels' DACs are on
+func (a *APU) dacs() [4]bool {
+	return [4]bool{
+		dacEnabled(a.reg(NR12_ADDRESS)),
+		dacEnabled(a.reg(NR22_ADDRESS)),
+		a.reg(NR30_ADDRESS)&0x80 != 0,
+		dacEnabled(a.reg(NR42_ADDRESS)),
+	}
+}
+
+// SetMuted mutes or unmutes a channel in the mix
+func (a *APU) SetMuted(c Channel, muted bool) {
+	a.muted[c] = muted
+}
+
+// Muted checks if a channel is left out of the mix
+func (a *APU) Muted(c Channel) bool {
+	return a.muted[c]
+}
+
+// mix pans the channels with NR51 and scales each side by the volume in NR50
+// https://gbdev.io/pandocs/Audio_details.html#mixer
+func (a *APU) mix() {
+	channels, dacs := a.Channels(), a.dacs()
+	nr50, nr51 := a.reg(NR50_ADDRESS), a.reg(NR51_ADDRESS)
+	volumes := [2]float32{float32(nr50>>4&0b111+1) / 8, float32(nr50&0b111+1) / 8}
+
+	var mixed [2]float32
+	var separate [4]Sample
+	anyDAC := false
+	for i, out := range channels {
+		if !dacs[i] {
+			continue
+		}
+		anyDAC = true
+
+		// the DAC maps 0-15 to 1 to -1, each channel is a quarter of the mix
+		analog := (1 - float32(out)/7.5) / 4
+		// right is bits 0-3, left is bits 4-7
+		var panned [2]float32
+		if nr51&(1<<(i+4)) != 0 {
+			panned[0] = analog * volumes[0]
+		}
+		if nr51&(1<<i) != 0 {
+			panned[1] = analog * volumes[1]
+		}
+
+		if !a.muted[i] {
+			mixed[0] += panned[0]
+			mixed[1] += panned[1]
+		}
+		if a.OnChannelSamples != nil {
+			separate[i] = highPass(&a.channelCapacitors[i], panned)
+		}
+	}
+
+	a.sample = Sample{}
+	if anyDAC {
+		a.sample = highPass(&a.capacitors, mixed)
+	}
+
+	if a.OnSample != nil {
+		a.OnSample(a.sample)
+	}
+	if a.OnChannelSamples != nil {
+		a.OnChannelSamples(separate)
+	}
+}
+
+// highPass filters out the DC offset with a capacitor for each side
+func highPass(capacitors *[2]float32, in [2]float32) Sample {
+	var out [2]float32
+	for side := range in {
+		out[side] = in[side] - capacitors[side]
+		capacitors[side] = in[side] - out[side]*charge
+	}
+
+	return Sample{Left: out[0], Right: out[1]}
+}
+
+// Output returns the current stereo sample
+func (a *APU) Output() Sample {
+	return a.sample
+}
+
+func (a *APU) Read(address uint16) byte {
+	switch {
+	case address >= WAVE_RAM_OFFSET && address < WAVE_RAM_OFFSET+WAVE_RAM_SIZE:
+		return a.wave.RAM[address-WAVE_RAM_OFFSET]
+	case address == NR52_ADDRESS:
+		status := a.reg(NR52_ADDRESS)&power | readMasks[address-NR10_ADDRESS]
+		for i, enabled := range []bool{a.square1.Enabled, a.square2.Enabled, a.wave.Enabled, a.noise.Enabled} {
+			if enabled {
+				status |= 1 << i
+			}
+		}
+		return status
+	case address >= NR10_ADDRESS && address < NR52_ADDRESS:
+		return a.reg(address) | readMasks[address-NR10_ADDRESS]
+	case address > NR52_ADDRESS && address < WAVE_RAM_OFFSET:
+		// unused
+		return 0xFF
+	default:
+		panic(errs.NewReadError(address, "apu"))
+	}
+}
+
+func (a *APU) Write(address uint16, data byte) {
+	switch {
+	case address >= WAVE_RAM_OFFSET && address < WAVE_RAM_OFFSET+WAVE_RAM_SIZE:
+		a.wave.RAM[address-WAVE_RAM_OFFSET] = data
+		return
+	case address == NR52_ADDRESS:
+		a.setPower(data&power != 0)
+		return
+	case address > NR52_ADDRESS && address < WAVE_RAM_OFFSET:
+		// unused
+		return
+	case address < NR10_ADDRESS || address > NR52_ADDRESS:
+		panic(errs.NewWriteError(address, "apu"))
+	}
+
+	if !a.powered() {
+		// registers are read only while the APU is off, except the length timers on the DMG
+		switch address {
+		case NR11_ADDRESS:
+			a.square1.Length.load(64, data&0x3F)
+		case NR21_ADDRESS:
+			a.square2.Length.load(64, data&0x3F)
+		case NR31_ADDRESS:
+			a.wave.Length.load(256, data)
+		case NR41_ADDRESS:
+			a.noise.Length.load(64, data&0x3F)
+		}
+		return
+	}
+
+	a.registers[address-NR10_ADDRESS] = data
+
+	switch address {
+	case NR10_ADDRESS:
+		// switching from subtraction to addition after it was used turns the channel off
+		if a.square1.Sweep.Negated && data&(1<<3) == 0 {
+			a.square1.Enabled = false
+		}
+	case NR11_ADDRESS:
+		a.square1.Length.load(64, data&0x3F)
+	case NR21_ADDRESS:
+		a.square2.Length.load(64, data&0x3F)
+	case NR31_ADDRESS:
+		a.wave.Length.load(256, data)
+	case NR41_ADDRESS:
+		a.noise.Length.load(64, data&0x3F)
+	case NR12_ADDRESS:
+		if !dacEnabled(data) {
+			a.square1.Enabled = false
+		}
+	case NR22_ADDRESS:
+		if !dacEnabled(data) {
+			a.square2.Enabled = false
+		}
+	case NR42_ADDRESS:
+		if !dacEnabled(data) {
+			a.noise.Enabled = false
+		}
+	case NR30_ADDRESS:
+		if data&0x80 == 0 {
+			a.wave.Enabled = false
+		}
+	case NR14_ADDRESS:
+		a.square1.Length.Enabled = data&(1<<6) != 0
+		if data&(1<<7) != 0 {
+			a.triggerSquare1()
+		}
+	case NR24_ADDRESS:
+		a.square2.Length.Enabled = data&(1<<6) != 0
+		if data&(1<<7) != 0 {
+			a.square2.trigger(a.reg(NR22_ADDRESS), a.frequency(NR23_ADDRESS, NR24_ADDRESS))
+		}
+	case NR34_ADDRESS:
+		a.wave.Length.Enabled = data&(1<<6) != 0
+		if data&(1<<7) != 0 {
+			a.wave.trigger(a.reg(NR30_ADDRESS), a.frequency(NR33_ADDRESS, NR34_ADDRESS))
+		}
+	case NR44_ADDRESS:
+		a.noise.Length.Enabled = data&(1<<6) != 0
+		if data&(1<<7) != 0 {
+			a.noise.trigger(a.reg(NR42_ADDRESS), a.reg(NR43_ADDRESS))
+		}
+	}
+}
+
+func (a *APU) triggerSquare1() {
+	freq := a.frequency(NR13_ADDRESS, NR14_ADDRESS)
+	a.square1.trigger(a.reg(NR12_ADDRESS), freq)
+
+	nr10 := a.reg(NR10_ADDRESS)
+	s := &a.square1.Sweep
+	s.Shadow = freq
+	s.Negated = false
+	s.reload(nr10)
+	s.Enabled = sweepPace(nr10) != 0 || nr10&0b111 != 0
+
+	if nr10&0b111 != 0 && s.next(nr10) > 2047 {
+		a.square1.Enabled = false
+	}
+}
+
+// setPower turns the APU on or off. Turning it off clears every register except wave ram, and
+// the length timers on the DMG
+func (a *APU) setPower(on bool) {
+	if on == a.powered() {
+		return
+	}
+
+	if on {
+		a.registers[NR52_ADDRESS-NR10_ADDRESS] = power
+		a.frameStep = 0
+		a.square1.Step, a.square2.Step = 0, 0
+		return
+	}
+
+	a.registers = [registerCount]byte{}
+	lengths := [4]uint16{a.square1.Length.Counter, a.square2.Length.Counter, a.wave.Length.Counter, a.noise.Length.Counter}
+	a.square1, a.square2, a.noise = square{}, square{}, noise{}
+	a.wave = wave{RAM: a.wave.RAM}
+	a.square1.Length.Counter, a.square2.Length.Counter, a.wave.Length.Counter, a.noise.Length.Counter = lengths[0], lengths[1], lengths[2], lengths[3]
+}
+
+// state is everything saved, in order
+func (a *APU) state() []any {
+	return []any{&a.registers, &a.square1, &a.square2, &a.wave, &a.noise, &a.frameStep}
+}
+
+// MarshalBinary encodes the registers, wave ram and channels for save states
+func (a *APU) MarshalBinary() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, v := range a.state() {
+		if err := binary.Write(buf, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores the APU from MarshalBinary
+func (a *APU) UnmarshalBinary(data []byte) error {
+	size := 0
+	for _, v := range a.state() {
+		size += binary.Size(v)
+	}
+	if len(data) != size {
+		return errs.NewInvalidStateError("apu", len(data))
+	}
+
+	r := bytes.NewReader(data)
+	for _, v := range a.state() {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	a.capacitors = [2]float32{}
+	a.channelCapacitors = [4][2]float32{}
+	return nil
+}
@@ -0,0 +1,222 @@
+package apu_test
+
+import (
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/apu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tick(a *apu.APU, cycles int) {
+	for i := 0; i < cycles; i++ {
+		a.Tick()
+	}
+}
+
+// playSquare2 triggers channel 2 at full volume with a 50% duty cycle
+func playSquare2(a *apu.APU, nr21 byte, nr24 byte) {
+	a.Write(apu.NR21_ADDRESS, nr21)
+	a.Write(apu.NR22_ADDRESS, 0xF0)
+	a.Write(apu.NR23_ADDRESS, 0x00)
+	a.Write(apu.NR24_ADDRESS, 0x87|nr24)
+}
+
+func TestRegisters(t *testing.T) {
+	a := apu.New()
+
+	tests := []struct {
+		address uint16
+		want    byte
+	}{
+		{apu.NR52_ADDRESS, 0xF1},
+		{apu.NR11_ADDRESS, 0xBF},
+		{apu.NR13_ADDRESS, 0xFF},
+		{apu.NR50_ADDRESS, 0x77},
+		{0xFF15, 0xFF},
+		{0xFF27, 0xFF},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, a.Read(tc.address), "%04X", tc.address)
+	}
+
+	// write only bits read back as 1
+	a.Write(apu.NR32_ADDRESS, 0x20)
+	assert.Equal(t, byte(0xBF), a.Read(apu.NR32_ADDRESS))
+}
+
+func TestPower(t *testing.T) {
+	a := apu.New()
+	a.Write(apu.WAVE_RAM_OFFSET, 0x12)
+
+	a.Write(apu.NR52_ADDRESS, 0x00)
+	assert.Equal(t, byte(0x70), a.Read(apu.NR52_ADDRESS))
+	assert.Equal(t, byte(0x00), a.Read(apu.NR50_ADDRESS))
+
+	// ignored while off, except wave ram
+	a.Write(apu.NR50_ADDRESS, 0x77)
+	assert.Equal(t, byte(0x00), a.Read(apu.NR50_ADDRESS))
+	assert.Equal(t, byte(0x12), a.Read(apu.WAVE_RAM_OFFSET))
+
+	a.Write(apu.NR52_ADDRESS, 0x80)
+	a.Write(apu.NR50_ADDRESS, 0x77)
+	assert.Equal(t, byte(0x77), a.Read(apu.NR50_ADDRESS))
+}
+
+func TestLength(t *testing.T) {
+	a := apu.New()
+
+	// 2 ticks of the length timer, which is clocked on every other step
+	playSquare2(a, 62, 0x40)
+	assert.Equal(t, byte(0xF3), a.Read(apu.NR52_ADDRESS))
+
+	a.ClockFrameSequencer()
+	a.ClockFrameSequencer()
+	assert.Equal(t, byte(0xF3), a.Read(apu.NR52_ADDRESS))
+	a.ClockFrameSequencer()
+	assert.Equal(t, byte(0xF1), a.Read(apu.NR52_ADDRESS))
+}
+
+func TestDAC(t *testing.T) {
+	a := apu.New()
+	playSquare2(a, 0, 0)
+	require.Equal(t, byte(0xF3), a.Read(apu.NR52_ADDRESS))
+
+	// turning the DAC off turns the channel off
+	a.Write(apu.NR22_ADDRESS, 0x00)
+	assert.Equal(t, byte(0xF1), a.Read(apu.NR52_ADDRESS))
+}
+
+func TestSweepOverflow(t *testing.T) {
+	a := apu.New()
+
+	// 2047 + 2047 >> 1 overflows straight away
+	a.Write(apu.NR10_ADDRESS, 0x11)
+	a.Write(apu.NR12_ADDRESS, 0xF0)
+	a.Write(apu.NR13_ADDRESS, 0xFF)
+	a.Write(apu.NR14_ADDRESS, 0x87)
+	assert.Equal(t, byte(0xF0), a.Read(apu.NR52_ADDRESS))
+
+	// 0x400 + 0x200 is fine until the first sweep on step 2, then 0x600 + 0x300 overflows
+	a.Write(apu.NR13_ADDRESS, 0x00)
+	a.Write(apu.NR14_ADDRESS, 0x84)
+	require.Equal(t, byte(0xF1), a.Read(apu.NR52_ADDRESS))
+
+	a.ClockFrameSequencer()
+	a.ClockFrameSequencer()
+	assert.Equal(t, byte(0xF1), a.Read(apu.NR52_ADDRESS))
+	a.ClockFrameSequencer()
+	assert.Equal(t, byte(0xF0), a.Read(apu.NR52_ADDRESS))
+}
+
+func TestOutput(t *testing.T) {
+	a := apu.New()
+	// only channel 2, only on the left
+	a.Write(apu.NR12_ADDRESS, 0x00)
+	a.Write(apu.NR51_ADDRESS, 0x20)
+	playSquare2(a, 0x80, 0)
+
+	levels := map[byte]bool{}
+	var left, right float32
+	for i := 0; i < 2048; i++ {
+		a.Tick()
+		levels[a.Channels()[1]] = true
+
+		out := a.Output()
+		if out.Left > left {
+			left = out.Left
+		}
+		if out.Right > right {
+			right = out.Right
+		}
+	}
+
+	assert.Equal(t, map[byte]bool{0: true, 15: true}, levels)
+	assert.Greater(t, left, float32(0))
+	assert.Equal(t, float32(0), right)
+}
+
+func TestMute(t *testing.T) {
+	a := apu.New()
+	a.Write(apu.NR12_ADDRESS, 0x00)
+	a.Write(apu.NR51_ADDRESS, 0x22)
+	playSquare2(a, 0x80, 0)
+	a.SetMuted(apu.Square2, true)
+	assert.True(t, a.Muted(apu.Square2))
+	assert.False(t, a.Muted(apu.Square1))
+
+	var separate [4]apu.Sample
+	a.OnChannelSamples = func(samples [4]apu.Sample) {
+		for i, s := range samples {
+			if s.Left > separate[i].Left {
+				separate[i] = s
+			}
+		}
+	}
+
+	for i := 0; i < 2048; i++ {
+		a.Tick()
+		assert.Equal(t, apu.Sample{}, a.Output())
+	}
+
+	// the muted channel is still exported on its own
+	assert.Greater(t, separate[apu.Square2].Left, float32(0))
+	assert.Greater(t, separate[apu.Square2].Right, float32(0))
+	assert.Equal(t, apu.Sample{}, separate[apu.Square1])
+	assert.Equal(t, "wave", apu.Wave.String())
+}
+
+func TestNoise(t *testing.T) {
+	a := apu.New()
+	a.Write(apu.NR42_ADDRESS, 0xF0)
+	a.Write(apu.NR43_ADDRESS, 0x00)
+	a.Write(apu.NR44_ADDRESS, 0x80)
+
+	levels := map[byte]bool{}
+	for i := 0; i < 256; i++ {
+		a.Tick()
+		levels[a.Channels()[3]] = true
+	}
+
+	assert.Equal(t, map[byte]bool{0: true, 15: true}, levels)
+}
+
+func TestWave(t *testing.T) {
+	a := apu.New()
+	for i := uint16(0); i < apu.WAVE_RAM_SIZE; i++ {
+		a.Write(apu.WAVE_RAM_OFFSET+i, 0xF0)
+	}
+
+	// 50% volume
+	a.Write(apu.NR30_ADDRESS, 0x80)
+	a.Write(apu.NR32_ADDRESS, 0x40)
+	a.Write(apu.NR33_ADDRESS, 0x00)
+	a.Write(apu.NR34_ADDRESS, 0x87)
+
+	levels := map[byte]bool{}
+	for i := 0; i < 256; i++ {
+		a.Tick()
+		levels[a.Channels()[2]] = true
+	}
+
+	assert.Equal(t, map[byte]bool{0: true, 7: true}, levels)
+}
+
+func TestState(t *testing.T) {
+	a := apu.New()
+	playSquare2(a, 0x80, 0)
+	tick(a, 100)
+
+	data, err := a.MarshalBinary()
+	require.NoError(t, err)
+
+	b := apu.New()
+	require.NoError(t, b.UnmarshalBinary(data))
+	tick(a, 100)
+	tick(b, 100)
+	assert.Equal(t, a.Channels(), b.Channels())
+	assert.Equal(t, a.Read(apu.NR52_ADDRESS), b.Read(apu.NR52_ADDRESS))
+
+	assert.Error(t, b.UnmarshalBinary(data[1:]))
+}
@@ -0,0 +1,73 @@
+package apu
+
+// Channel state is kept in structs with exported fields so it can be saved with encoding/binary.
+
+// length turns a channel off after a number of 256 Hz ticks
+// https://gbdev.io/pandocs/Audio.html#length-timer
+type length struct {
+	Enabled bool
+	Counter uint16
+}
+
+// load sets the counter from the length bits of NRx1, the channel plays for max - value ticks
+func (l *length) load(max uint16, value byte) {
+	l.Counter = max - uint16(value)
+}
+
+// trigger reloads an expired counter
+func (l *length) trigger(max uint16) {
+	if l.Counter == 0 {
+		l.Counter = max
+	}
+}
+
+// clock counts down, returning true when the channel should be turned off
+func (l *length) clock() bool {
+	if !l.Enabled || l.Counter == 0 {
+		return false
+	}
+
+	l.Counter--
+	return l.Counter == 0
+}
+
+// envelope changes the volume of the square and noise channels every few 64 Hz ticks, NRx2 is:
+//
+//	bits 7-4: initial volume, bit 3: direction (1 is up), bits 2-0: pace
+//
+// https://gbdev.io/pandocs/Audio_Registers.html#ff12--nr12-channel-1-volume--envelope
+type envelope struct {
+	Volume byte
+	Timer  byte
+}
+
+func (e *envelope) trigger(nrx2 byte) {
+	e.Volume = nrx2 >> 4
+	e.Timer = nrx2 & 0b111
+}
+
+func (e *envelope) clock(nrx2 byte) {
+	pace := nrx2 & 0b111
+	if pace == 0 {
+		return
+	}
+
+	if e.Timer > 0 {
+		e.Timer--
+	}
+	if e.Timer > 0 {
+		return
+	}
+	e.Timer = pace
+
+	if nrx2&(1<<3) != 0 && e.Volume < 15 {
+		e.Volume++
+	} else if nrx2&(1<<3) == 0 && e.Volume > 0 {
+		e.Volume--
+	}
+}
+
+// dacEnabled checks the upper 5 bits of NRx2, the square and noise DACs are off when they're 0
+func dacEnabled(nrx2 byte) bool {
+	return nrx2&0xF8 != 0
+}
@@ -0,0 +1,61 @@
+package apu
+
+// divisors selected by the lower 3 bits of NR43
+var noiseDivisors = [8]uint32{8, 16, 32, 48, 64, 80, 96, 112}
+
+// noise is channel 4, which outputs the low bit of a linear feedback shift register
+// https://gbdev.io/pandocs/Audio_details.html#noise-channel-ch4
+type noise struct {
+	Enabled  bool
+	Length   length
+	Envelope envelope
+	// T-cycles until the LFSR is clocked
+	Timer uint32
+	LFSR  uint16
+}
+
+// period is divisor << shift T-cycles, NR43 is:
+//
+//	bits 7-4: clock shift, bit 3: 7-bit width, bits 2-0: divisor
+func noisePeriod(nr43 byte) uint32 {
+	return noiseDivisors[nr43&0b111] << (nr43 >> 4)
+}
+
+func (n *noise) tick(nr43 byte) {
+	if n.Timer > 0 {
+		n.Timer--
+	}
+	if n.Timer > 0 {
+		return
+	}
+	n.Timer = noisePeriod(nr43)
+
+	// shifts of 14 and 15 are invalid, the LFSR doesn't clock
+	if nr43>>4 >= 14 {
+		return
+	}
+
+	xor := (n.LFSR ^ n.LFSR>>1) & 1
+	n.LFSR = n.LFSR>>1 | xor<<14
+	// 7-bit mode also copies it into bit 6, making the sequence much shorter
+	if nr43&(1<<3) != 0 {
+		n.LFSR = n.LFSR&^(1<<6) | xor<<6
+	}
+}
+
+func (n *noise) trigger(nrx2, nr43 byte) {
+	n.Enabled = dacEnabled(nrx2)
+	n.Length.trigger(64)
+	n.Envelope.trigger(nrx2)
+	n.Timer = noisePeriod(nr43)
+	n.LFSR = 0x7FFF
+}
+
+// output is the digital output, 0-15
+func (n *noise) output() byte {
+	if !n.Enabled || n.LFSR&1 != 0 {
+		return 0
+	}
+
+	return n.Envelope.Volume
+}
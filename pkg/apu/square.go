@@ -0,0 +1,90 @@
+package apu
+
+// waveforms for each duty cycle, a bit per step: 12.5%, 25%, 50% and 75%
+// https://gbdev.io/pandocs/Audio_Registers.html#ff11--nr11-channel-1-length-timer--duty-cycle
+var dutyCycles = [4]byte{
+	0b00000001,
+	0b10000001,
+	0b10000111,
+	0b01111110,
+}
+
+// square is channels 1 and 2, channel 2 doesn't use the sweep
+type square struct {
+	Enabled  bool
+	Length   length
+	Envelope envelope
+	// T-cycles until the next step of the waveform
+	Timer uint16
+	Step  byte
+	Sweep sweep
+}
+
+// period is (2048 - frequency) * 4 T-cycles per step
+func squarePeriod(freq uint16) uint16 {
+	return (2048 - freq) * 4
+}
+
+func (s *square) tick(freq uint16) {
+	if s.Timer > 0 {
+		s.Timer--
+	}
+	if s.Timer == 0 {
+		s.Timer = squarePeriod(freq)
+		s.Step = (s.Step + 1) & 7
+	}
+}
+
+func (s *square) trigger(nrx2 byte, freq uint16) {
+	s.Enabled = dacEnabled(nrx2)
+	s.Length.trigger(64)
+	s.Envelope.trigger(nrx2)
+	s.Timer = squarePeriod(freq)
+}
+
+// output is the digital output, 0-15
+func (s *square) output(nrx1 byte) byte {
+	if !s.Enabled {
+		return 0
+	}
+
+	duty := dutyCycles[nrx1>>6]
+	return (duty >> (7 - s.Step) & 1) * s.Envelope.Volume
+}
+
+// sweep periodically changes channel 1's frequency, NR10 is:
+//
+//	bits 6-4: pace, bit 3: direction (1 is down), bits 2-0: step
+//
+// https://gbdev.io/pandocs/Audio_Registers.html#ff10--nr10-channel-1-sweep
+type sweep struct {
+	Enabled bool
+	Timer   byte
+	// Shadow is the frequency the sweep works from
+	Shadow uint16
+	// Negated is set once a calculation subtracted, switching back to addition then turns the channel off
+	Negated bool
+}
+
+func sweepPace(nr10 byte) byte {
+	return nr10 >> 4 & 0b111
+}
+
+// reload the timer, a pace of 0 is treated as 8
+func (s *sweep) reload(nr10 byte) {
+	s.Timer = sweepPace(nr10)
+	if s.Timer == 0 {
+		s.Timer = 8
+	}
+}
+
+// next calculates the next frequency, it's past 2047 when the channel should be turned off
+func (s *sweep) next(nr10 byte) uint16 {
+	delta := s.Shadow >> (nr10 & 0b111)
+	if nr10&(1<<3) != 0 {
+		s.Negated = true
+		return s.Shadow - delta
+	}
+
+	return s.Shadow + delta
+}
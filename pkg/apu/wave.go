@@ -0,0 +1,58 @@
+package apu
+
+// wave is channel 3, which plays 32 4-bit samples from wave ram
+// https://gbdev.io/pandocs/Audio_details.html#ch3-wave
+type wave struct {
+	Enabled bool
+	Length  length
+	// T-cycles until the next sample
+	Timer uint16
+	// Position of the sample being played, and the last sample read
+	Position byte
+	Sample   byte
+	RAM      [WAVE_RAM_SIZE]byte
+}
+
+// period is (2048 - frequency) * 2 T-cycles per sample
+func wavePeriod(freq uint16) uint16 {
+	return (2048 - freq) * 2
+}
+
+func (w *wave) tick(freq uint16) {
+	if w.Timer > 0 {
+		w.Timer--
+	}
+	if w.Timer == 0 {
+		w.Timer = wavePeriod(freq)
+		w.Position = (w.Position + 1) & 31
+
+		// upper nibble first
+		w.Sample = w.RAM[w.Position/2]
+		if w.Position%2 == 0 {
+			w.Sample >>= 4
+		}
+		w.Sample &= 0x0F
+	}
+}
+
+func (w *wave) trigger(nr30 byte, freq uint16) {
+	w.Enabled = nr30&0x80 != 0
+	w.Length.trigger(256)
+	// the first sample played is position 1, the buffer isn't refilled until then
+	w.Timer = wavePeriod(freq)
+	w.Position = 0
+}
+
+// output is the digital output, 0-15. Bits 6-5 of NR32 select the volume: mute, 100%, 50% or 25%
+func (w *wave) output(nr32 byte) byte {
+	if !w.Enabled {
+		return 0
+	}
+
+	level := nr32 >> 5 & 0b11
+	if level == 0 {
+		return 0
+	}
+
+	return w.Sample >> (level - 1)
+}
@@ -0,0 +1,47 @@
+package audio
+
+import (
+	"github.com/robherley/go-gameboy/pkg/apu"
+)
+
+// Common output sample rates
+const (
+	Rate44100 = 44100
+	Rate48000 = 48000
+)
+
+// SampleSink receives stereo samples at the rate it was set up with, ie: a WAV file or a sound card.
+// The slice is reused after WriteSamples returns, sinks must copy anything they keep
+type SampleSink interface {
+	WriteSamples(samples []apu.Sample) error
+}
+
+// SinkFunc adapts a func to a SampleSink
+type SinkFunc func(samples []apu.Sample) error
+
+func (f SinkFunc) WriteSamples(samples []apu.Sample) error {
+	return f(samples)
+}
+
+// RateController is a sink that plays samples in real time from a queue, ie: a sound card. The
+// emulator and the sound card run on different clocks, so the queue slowly drains or fills.
+// RateAdjust is how much faster samples should be produced to keep it from running dry or lagging
+type RateController interface {
+	SampleSink
+	RateAdjust() float64
+}
+
+// MaxRateAdjust is the most DynamicRate adjusts by, the change in pitch is too small to hear
+const MaxRateAdjust = 0.005
+
+// DynamicRate returns the adjustment that keeps a queue half full, from -MaxRateAdjust when it's
+// full to MaxRateAdjust when it's empty
+// https://docs.libretro.com/development/cores/dynamic-rate-control/
+func DynamicRate(queued, capacity int) float64 {
+	fill := float64(queued) / float64(capacity)
+	if fill > 1 {
+		fill = 1
+	}
+
+	return MaxRateAdjust * (1 - 2*fill)
+}
@@ -0,0 +1,110 @@
+package audio_test
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/apu"
+	"github.com/robherley/go-gameboy/pkg/audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resampleTone resamples a second of a sine wave at freq, returning the peak of the second half
+// of the output, after the filter has settled
+func resampleTone(t *testing.T, freq float64, rate int) (int, float32) {
+	r := audio.NewResampler(apu.SampleRate, rate)
+	for i := 0; i < apu.SampleRate; i++ {
+		v := float32(0.5 * math.Sin(2*math.Pi*float64(i)*freq/apu.SampleRate))
+		r.Push(apu.Sample{Left: v, Right: -v})
+	}
+
+	out := r.Flush()
+	peak := float32(0)
+	for _, s := range out[len(out)/2:] {
+		peak = float32(math.Max(float64(peak), math.Abs(float64(s.Left))))
+		require.InDelta(t, -s.Left, s.Right, 1e-6)
+	}
+
+	return len(out), peak
+}
+
+func TestResampler(t *testing.T) {
+	tests := []struct {
+		name     string
+		freq     float64
+		rate     int
+		min, max float32
+	}{
+		{"audible", 440, audio.Rate44100, 0.49, 0.51},
+		{"treble", 15000, audio.Rate48000, 0.49, 0.51},
+		// tones past nyquist would alias if they were just decimated
+		{"ultrasonic", 100000, audio.Rate44100, 0, 0.01},
+		{"past nyquist", 30000, audio.Rate48000, 0, 0.01},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			n, peak := resampleTone(t, tc.freq, tc.rate)
+			assert.InDelta(t, tc.rate, n, 1)
+			assert.GreaterOrEqual(t, peak, tc.min)
+			assert.LessOrEqual(t, peak, tc.max)
+		})
+	}
+}
+
+func TestRateAdjust(t *testing.T) {
+	r := audio.NewResampler(apu.SampleRate, audio.Rate48000)
+	r.SetRateAdjust(audio.MaxRateAdjust)
+	for i := 0; i < apu.SampleRate; i++ {
+		r.Push(apu.Sample{})
+	}
+	assert.InDelta(t, 48240, len(r.Flush()), 1)
+
+	r.SetRateAdjust(-audio.MaxRateAdjust)
+	for i := 0; i < apu.SampleRate; i++ {
+		r.Push(apu.Sample{})
+	}
+	assert.InDelta(t, 47760, len(r.Flush()), 1)
+}
+
+func TestDynamicRate(t *testing.T) {
+	assert.Equal(t, audio.MaxRateAdjust, audio.DynamicRate(0, 1000))
+	assert.Equal(t, 0.0, audio.DynamicRate(500, 1000))
+	assert.Equal(t, -audio.MaxRateAdjust, audio.DynamicRate(1000, 1000))
+	assert.Equal(t, -audio.MaxRateAdjust, audio.DynamicRate(5000, 1000))
+}
+
+func TestWAVWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	w := audio.NewWAVWriter(f, audio.Rate48000)
+	require.NoError(t, w.WriteSamples([]apu.Sample{{Left: 1, Right: -1}, {Left: 0, Right: 0.5}}))
+	require.NoError(t, w.WriteSamples([]apu.Sample{{Left: -0.5, Right: 0}}))
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, data, 44+3*4)
+
+	assert.Equal(t, "RIFF", string(data[0:4]))
+	assert.Equal(t, uint32(36+12), binary.LittleEndian.Uint32(data[4:]))
+	assert.Equal(t, "WAVEfmt ", string(data[8:16]))
+	assert.Equal(t, uint16(2), binary.LittleEndian.Uint16(data[22:]))
+	assert.Equal(t, uint32(48000), binary.LittleEndian.Uint32(data[24:]))
+	assert.Equal(t, uint32(48000*4), binary.LittleEndian.Uint32(data[28:]))
+	assert.Equal(t, "data", string(data[36:40]))
+	assert.Equal(t, uint32(12), binary.LittleEndian.Uint32(data[40:]))
+
+	samples := make([]int16, 6)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[44+i*2:]))
+	}
+	assert.Equal(t, []int16{32767, -32767, 0, 16384, -16384, 0}, samples)
+}
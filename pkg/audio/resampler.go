@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"math"
+
+	"github.com/robherley/go-gameboy/pkg/apu"
+)
+
+// The APU changes its output a million times a second, resampling it down to 44.1 kHz by picking
+// samples would alias everything above 22 kHz back into the audible range. The resampler removes
+// it in two stages:
+//
+//  1. the input is averaged over each period of an intermediate rate, a few times the output
+//     rate. The APU's output is a step function, so the average is exact and cheap
+//  2. a windowed sinc low pass filter cuts off everything above the output's nyquist frequency
+//     and decimates to the output rate
+
+const (
+	// oversample is the intermediate rate as a multiple of the output rate
+	oversample = 4
+	// taps in the low pass filter
+	taps = 128
+	// cutoff as a fraction of the output's nyquist frequency, leaving room for the transition band
+	cutoff = 0.9
+)
+
+type Resampler struct {
+	inRate, outRate int
+	// adjust is how much faster than outRate samples are output, see SetRateAdjust
+	adjust float64
+	// input samples per intermediate sample, and how far into the current one the input is
+	step     float64
+	position float64
+	sum      [2]float64
+	// intermediate samples, a ring buffer written at next
+	history [taps][2]float64
+	next    int
+	// intermediate samples until the next output
+	countdown int
+	filter    [taps]float64
+	out       []apu.Sample
+}
+
+// NewResampler creates a resampler from the inRate to the outRate, usually apu.SampleRate
+func NewResampler(inRate, outRate int) *Resampler {
+	r := &Resampler{
+		inRate:    inRate,
+		outRate:   outRate,
+		step:      float64(inRate) / float64(outRate*oversample),
+		countdown: oversample,
+	}
+
+	// blackman windowed sinc, normalized so it has unity gain
+	fc := cutoff * 0.5 / oversample
+	total := 0.0
+	for i := range r.filter {
+		n := float64(i) - float64(taps-1)/2
+		sinc := 2 * fc
+		if n != 0 {
+			sinc = math.Sin(2*math.Pi*fc*n) / (math.Pi * n)
+		}
+		window := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/(taps-1)) + 0.08*math.Cos(4*math.Pi*float64(i)/(taps-1))
+
+		r.filter[i] = sinc * window
+		total += r.filter[i]
+	}
+	for i := range r.filter {
+		r.filter[i] /= total
+	}
+
+	return r
+}
+
+// OutRate returns the rate samples are output at
+func (r *Resampler) OutRate() int {
+	return r.outRate
+}
+
+// SetRateAdjust outputs samples slightly faster or slower than the output rate, ie: 0.005 outputs
+// 0.5% more samples. It's for keeping a sound card's queue filled, see DynamicRate
+func (r *Resampler) SetRateAdjust(adjust float64) {
+	r.adjust = adjust
+	r.step = float64(r.inRate) / (float64(r.outRate) * (1 + adjust) * oversample)
+}
+
+// RateAdjust returns the adjustment from SetRateAdjust
+func (r *Resampler) RateAdjust() float64 {
+	return r.adjust
+}
+
+// Push an input sample
+func (r *Resampler) Push(s apu.Sample) {
+	in := [2]float64{float64(s.Left), float64(s.Right)}
+
+	// the sample lasts one unit of time, which can straddle intermediate samples
+	remaining := 1.0
+	for remaining > 0 {
+		// the step can shrink past the position when the rate is adjusted
+		room := math.Max(r.step-r.position, 0)
+		if remaining < room {
+			r.accumulate(in, remaining)
+			r.position += remaining
+			return
+		}
+
+		r.accumulate(in, room)
+		remaining -= room
+		r.intermediate([2]float64{r.sum[0] / r.step, r.sum[1] / r.step})
+		r.sum = [2]float64{}
+		r.position = 0
+	}
+}
+
+func (r *Resampler) accumulate(in [2]float64, weight float64) {
+	r.sum[0] += in[0] * weight
+	r.sum[1] += in[1] * weight
+}
+
+// intermediate adds a sample to the filter's history, outputting every oversample samples
+func (r *Resampler) intermediate(s [2]float64) {
+	r.history[r.next] = s
+	r.next = (r.next + 1) % taps
+
+	r.countdown--
+	if r.countdown > 0 {
+		return
+	}
+	r.countdown = oversample
+
+	var out [2]float64
+	for i, coeff := range r.filter {
+		h := r.history[(r.next+i)%taps]
+		out[0] += h[0] * coeff
+		out[1] += h[1] * coeff
+	}
+
+	r.out = append(r.out, apu.Sample{Left: clamp(out[0]), Right: clamp(out[1])})
+}
+
+func clamp(v float64) float32 {
+	if v > 1 {
+		return 1
+	}
+	if v < -1 {
+		return -1
+	}
+	return float32(v)
+}
+
+// Flush returns the samples output since the last flush. The slice is reused by the next Push
+func (r *Resampler) Flush() []apu.Sample {
+	out := r.out
+	r.out = r.out[:0]
+	return out
+}
@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/robherley/go-gameboy/pkg/apu"
+)
+
+// WAVWriter writes 16-bit stereo PCM. The sizes in the header aren't known until the end, they're
+// filled in by Close
+// http://soundfile.sapp.org/doc/WaveFormat/
+type WAVWriter struct {
+	w    io.WriteSeeker
+	rate int
+	// bytes of samples written
+	size    uint32
+	started bool
+	buf     []byte
+}
+
+const (
+	wavChannels      = 2
+	wavBitsPerSample = 16
+	wavHeaderSize    = 44
+	// the largest size a RIFF header can hold
+	wavMaxSize = math.MaxUint32 - wavHeaderSize
+)
+
+// ErrorWAVTooLarge is returned when writing past the 4GB limit of the format
+var ErrorWAVTooLarge = errors.New("wav file is too large")
+
+// NewWAVWriter creates a writer for samples at the sample rate
+func NewWAVWriter(w io.WriteSeeker, rate int) *WAVWriter {
+	return &WAVWriter{w: w, rate: rate}
+}
+
+// wavHeader is the RIFF header, the fmt chunk and the start of the data chunk
+type wavHeader struct {
+	RIFF          [4]byte
+	RIFFSize      uint32
+	WAVE          [4]byte
+	Fmt           [4]byte
+	FmtSize       uint32
+	Format        uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+	Data          [4]byte
+	DataSize      uint32
+}
+
+func (ww *WAVWriter) header() wavHeader {
+	blockAlign := wavChannels * wavBitsPerSample / 8
+
+	return wavHeader{
+		RIFF:     [4]byte{'R', 'I', 'F', 'F'},
+		RIFFSize: wavHeaderSize - 8 + ww.size,
+		WAVE:     [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:      [4]byte{'f', 'm', 't', ' '},
+		FmtSize:  16,
+		// PCM
+		Format:        1,
+		Channels:      wavChannels,
+		SampleRate:    uint32(ww.rate),
+		ByteRate:      uint32(ww.rate * blockAlign),
+		BlockAlign:    uint16(blockAlign),
+		BitsPerSample: wavBitsPerSample,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      ww.size,
+	}
+}
+
+// WriteSamples appends the samples to the file
+func (ww *WAVWriter) WriteSamples(samples []apu.Sample) error {
+	if !ww.started {
+		if err := binary.Write(ww.w, binary.LittleEndian, ww.header()); err != nil {
+			return err
+		}
+		ww.started = true
+	}
+
+	if uint64(ww.size)+uint64(len(samples)*4) > wavMaxSize {
+		return ErrorWAVTooLarge
+	}
+
+	if cap(ww.buf) < len(samples)*4 {
+		ww.buf = make([]byte, len(samples)*4)
+	}
+	ww.buf = ww.buf[:len(samples)*4]
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(ww.buf[i*4:], uint16(PCM(s.Left)))
+		binary.LittleEndian.PutUint16(ww.buf[i*4+2:], uint16(PCM(s.Right)))
+	}
+
+	n, err := ww.w.Write(ww.buf)
+	ww.size += uint32(n)
+	return err
+}
+
+// Close fills in the sizes in the header, it doesn't close the underlying writer
+func (ww *WAVWriter) Close() error {
+	if _, err := ww.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(ww.w, binary.LittleEndian, ww.header()); err != nil {
+		return err
+	}
+
+	_, err := ww.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// PCM converts -1 to 1 to a signed 16 bit sample
+func PCM(v float32) int16 {
+	return int16(math.Round(float64(v) * math.MaxInt16))
+}
@@ -2,34 +2,187 @@ package cartridge
 
 import (
 	"fmt"
-	"os"
+	"hash/crc32"
+	"time"
+
+	errs "github.com/robherley/go-gameboy/pkg/errors"
 )
 
 type Cartridge struct {
 	Data []byte
 	Size int
-}
-
-func FromFile(filepath string) (*Cartridge, error) {
-	data, err := os.ReadFile(filepath)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read cartridge file: %w", err)
-	}
+	// RAM is the external (optionally battery backed) ram at 0xA000-0xBFFF
+	RAM []byte
 
-	return FromBytes(data)
+	mbc mbc
+	// clock is the real time clock of MBC3 cartridges with a timer, otherwise nil
+	clock *rtc
+	// supported is false when the memory bank controller isn't emulated, the rom is mapped
+	// without banking
+	supported bool
 }
 
+// the header ends at 0x14F, anything smaller can't be a rom
+const minimumSize = 0x150
+
 func FromBytes(data []byte) (*Cartridge, error) {
-	return &Cartridge{
+	if len(data) < minimumSize {
+		return nil, fmt.Errorf("cartridge is too small: %d bytes", len(data))
+	}
+
+	c := &Cartridge{
 		Data: data,
 		Size: len(data),
-	}, nil
+	}
+
+	c.RAM = make([]byte, ramSizes[c.RAMSize()])
+
+	c.supported = true
+	switch t := c.CartridgeType(); t {
+	case ROM_ONLY, ROM_RAM, ROM_RAM_BATTERY:
+		c.mbc = &romOnly{c.Data, c.RAM}
+	case MBC1, MB1_RAM, MBC1_RAM_BATTERY:
+		c.mbc = newMBC1(c.Data, c.RAM)
+	case MBC2, MBC2_BATTERY:
+		c.RAM = make([]byte, MBC2RAMSize)
+		c.mbc = newMBC2(c.Data, c.RAM)
+	case MBC3_TIMER_BATTERY, MBC3_TIMER_RAM_BATTERY, MBC3, MBC3_RAM, MBC3_RAM_BATTERY:
+		if t == MBC3_TIMER_BATTERY || t == MBC3_TIMER_RAM_BATTERY {
+			c.clock = &rtc{}
+		}
+		c.mbc = newMBC3(c.Data, c.RAM, c.clock)
+	case MBC5, MBC5_RAM, MBC5_RAM_BATTERY, MBC5_RUMBLE, MBC5_RUMBLE_RAM, MBC5_RUMBLE_RAM_BATTERY:
+		c.mbc = newMBC5(c.Data, c.RAM, t == MBC5_RUMBLE || t == MBC5_RUMBLE_RAM || t == MBC5_RUMBLE_RAM_BATTERY)
+	default:
+		// the first 32KiB of most games still run, so they're mapped like a rom without an mbc
+		// rather than refused
+		c.supported = false
+		c.mbc = &romOnly{c.Data, c.RAM}
+	}
+
+	return c, nil
+}
+
+// Supported checks if the cartridge's memory bank controller is emulated. Unsupported cartridges
+// are loaded without banking, which only gets most games as far as their first bank switch
+func (c *Cartridge) Supported() bool {
+	return c.supported
+}
+
+// Tick advances the cartridge by one M-cycle, only the MBC3 clock needs it
+func (c *Cartridge) Tick() {
+	if c.clock != nil {
+		c.clock.tick()
+	}
+}
+
+// HasClock checks if the cartridge has a real time clock
+func (c *Cartridge) HasClock() bool {
+	return c.clock != nil
+}
+
+// Clock returns the seconds counted by the real time clock, or 0 without one
+func (c *Cartridge) Clock() int64 {
+	if c.clock == nil {
+		return 0
+	}
+	return int64(c.clock.seconds())
+}
+
+// SetClock starts the real time clock from the seconds, so runs that start from it are deterministic
+func (c *Cartridge) SetClock(seconds int64) {
+	if c.clock == nil {
+		return
+	}
+
+	*c.clock = rtc{}
+	c.clock.advance(uint64(seconds))
+	c.clock.latch()
+}
+
+// RTC encodes the real time clock the way it's appended to saves and stored in BESS RTC blocks,
+// see RTCSize. It's nil without a clock
+func (c *Cartridge) RTC(now time.Time) []byte {
+	if c.clock == nil {
+		return nil
+	}
+	return c.clock.marshal(now)
+}
+
+// SetRTC restores the real time clock from RTC, catching up with the time since it was saved
+func (c *Cartridge) SetRTC(data []byte, now time.Time) error {
+	if c.clock == nil {
+		return fmt.Errorf("%w: %s cartridges don't have a clock", errs.ErrorInvalidState, c.CartridgeType())
+	}
+	return c.clock.unmarshal(data, now)
 }
 
 func (c *Cartridge) Read(address uint16) byte {
-	return c.Data[address]
+	return c.mbc.Read(address)
 }
 
 func (c *Cartridge) Write(address uint16, value byte) {
-	// panic(errs.NewWriteError(address, "cartridge"))
+	c.mbc.Write(address, value)
+}
+
+// ROMBank returns the bank currently switched into 0x4000-0x7FFF
+func (c *Cartridge) ROMBank() int {
+	return c.mbc.ROMBank()
+}
+
+// https://gbdev.io/pandocs/MBCs.html
+// ROM is split in 16KiB banks, bank 0 is always mapped to 0x0000-0x3FFF and
+// the remaining banks are switched into 0x4000-0x7FFF
+const ROMBankSize = 0x4000
+
+// ROMBanks returns the number of 16KiB banks in the cartridge ROM
+func (c *Cartridge) ROMBanks() int {
+	return (c.Size + ROMBankSize - 1) / ROMBankSize
+}
+
+// ReadBank reads a byte from ROM as if the given bank was switched in, where
+// address is the CPU address (0x0000-0x3FFF for bank 0, 0x4000-0x7FFF otherwise)
+func (c *Cartridge) ReadBank(bank int, address uint16) byte {
+	offset := bank*ROMBankSize + int(address%ROMBankSize)
+	if offset >= c.Size {
+		panic(errs.NewReadError(address, fmt.Sprintf("cartridge rom bank %d", bank)))
+	}
+
+	return c.Data[offset]
+}
+
+// MBCRegisters returns the writes that put the memory bank controller of a freshly loaded cartridge into its current state
+func (c *Cartridge) MBCRegisters() []RegisterWrite {
+	return c.mbc.registers()
+}
+
+// Checksum is a CRC-32 of the entire ROM, used to make sure save states belong to this cartridge
+func (c *Cartridge) Checksum() uint32 {
+	return crc32.ChecksumIEEE(c.Data)
+}
+
+// MarshalBinary encodes the memory bank controller registers and cartridge RAM for save states
+func (c *Cartridge) MarshalBinary() ([]byte, error) {
+	state := c.mbc.state()
+
+	data := make([]byte, 0, 1+len(state)+len(c.RAM))
+	data = append(data, byte(len(state)))
+	data = append(data, state...)
+	return append(data, c.RAM...), nil
+}
+
+// UnmarshalBinary restores the cartridge from MarshalBinary
+func (c *Cartridge) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || len(data) != 1+int(data[0])+len(c.RAM) {
+		return errs.NewInvalidStateError("cartridge", len(data))
+	}
+
+	state, ram := data[1:1+data[0]], data[1+data[0]:]
+	if err := c.mbc.setState(state); err != nil {
+		return err
+	}
+
+	// the mbc shares the slice, so it's copied in place
+	copy(c.RAM, ram)
+	return nil
 }
@@ -11,6 +11,18 @@ func (c CartridgeType) String() string {
 	return "unknown"
 }
 
+// HasBattery checks if the cartridge ram is battery backed, so it keeps the game's saves
+func (c CartridgeType) HasBattery() bool {
+	switch c {
+	case MBC1_RAM_BATTERY, MBC2_BATTERY, ROM_RAM_BATTERY, MMM01_RAM_BATTERY, MBC3_TIMER_BATTERY,
+		MBC3_TIMER_RAM_BATTERY, MBC3_RAM_BATTERY, MBC5_RAM_BATTERY, MBC5_RUMBLE_RAM_BATTERY,
+		MBC7_SENSOR_RUMBLE_RAM_BATTERY, HUC1_RAM_BATTERY:
+		return true
+	}
+
+	return false
+}
+
 const (
 	ROM_ONLY                       CartridgeType = 0x00
 	MBC1                           CartridgeType = 0x01
@@ -0,0 +1,68 @@
+//go:build !js
+
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// FromFile reads a rom from disk. It's left out of WebAssembly builds, which get roms from
+// JavaScript and use FromBytes
+func FromFile(filepath string) (*Cartridge, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cartridge file: %w", err)
+	}
+
+	return FromBytes(data)
+}
+
+// LoadRAM restores the cartridge ram from a save file. A missing file isn't an error, the game
+// hasn't saved yet. Cartridges with a clock restore it from the end of the save, otherwise
+// anything after the ram is ignored
+func (c *Cartridge) LoadRAM(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read save: %w", err)
+	}
+
+	if len(data) < len(c.RAM) {
+		return fmt.Errorf("save %s is too small: %d bytes, want %d", path, len(data), len(c.RAM))
+	}
+
+	// the mbc shares the slice, so it's copied in place
+	copy(c.RAM, data)
+
+	if footer := data[len(c.RAM):]; c.clock != nil && len(footer) > 0 {
+		if err := c.SetRTC(footer, time.Now()); err != nil {
+			return fmt.Errorf("save %s has a bad clock: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// SaveRAM writes the cartridge ram, followed by the clock if it has one, to a save file. It's
+// written to a temporary file first, so the old save survives if writing fails part way
+func (c *Cartridge) SaveRAM(path string) error {
+	data := append(append([]byte{}, c.RAM...), c.RTC(time.Now())...)
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("unable to write save: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("unable to write save: %w", err)
+	}
+
+	return nil
+}
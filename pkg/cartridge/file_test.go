@@ -0,0 +1,67 @@
+package cartridge_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robherley/go-gameboy/internal/testutil"
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBatteryCartridge creates a rom only cartridge with 8KiB of battery backed ram
+func newBatteryCartridge(t *testing.T) *cartridge.Cartridge {
+	rom := testutil.ROM()
+	rom[0x147] = byte(cartridge.ROM_RAM_BATTERY)
+	rom[0x149] = 0x02
+
+	cart, err := cartridge.FromBytes(rom)
+	require.NoError(t, err)
+	require.True(t, cart.CartridgeType().HasBattery())
+	return cart
+}
+
+func TestSaveRAM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+
+	cart := newBatteryCartridge(t)
+	require.NoError(t, cart.LoadRAM(path), "no save yet")
+
+	cart.Write(0xA010, 0x42)
+	require.NoError(t, cart.SaveRAM(path))
+
+	other := newBatteryCartridge(t)
+	require.NoError(t, other.LoadRAM(path))
+	assert.Equal(t, byte(0x42), other.Read(0xA010))
+
+	// other emulators can append a clock
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, append(data, make([]byte, 48)...), 0o644))
+	require.NoError(t, other.LoadRAM(path))
+	assert.Equal(t, byte(0x42), other.Read(0xA010))
+
+	require.NoError(t, os.WriteFile(path, data[:100], 0o644))
+	assert.Error(t, other.LoadRAM(path))
+}
+
+func TestSaveRAMClock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+
+	cart := newBankedCartridge(t, cartridge.MBC3_TIMER_RAM_BATTERY, 4, 0x02)
+	cart.SetClock(3600)
+	require.NoError(t, cart.SaveRAM(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, data, len(cart.RAM)+cartridge.RTCSize)
+
+	other := newBankedCartridge(t, cartridge.MBC3_TIMER_RAM_BATTERY, 4, 0x02)
+	require.NoError(t, other.LoadRAM(path))
+	assert.GreaterOrEqual(t, other.Clock(), int64(3600))
+
+	require.NoError(t, os.WriteFile(path, data[:len(cart.RAM)+10], 0o644))
+	assert.Error(t, other.LoadRAM(path))
+}
@@ -0,0 +1,525 @@
+package cartridge
+
+import errs "github.com/robherley/go-gameboy/pkg/errors"
+
+// https://gbdev.io/pandocs/MBCs.html
+
+// mbc is a memory bank controller, it maps rom and ram banks into the address space
+type mbc interface {
+	Read(address uint16) byte
+	Write(address uint16, value byte)
+	// ROMBank returns the bank that is currently switched into 0x4000-0x7FFF
+	ROMBank() int
+	// state and setState save and restore the banking registers
+	state() []byte
+	setState(data []byte) error
+	// registers returns the writes that put a reset mbc into its current state
+	registers() []RegisterWrite
+}
+
+// RegisterWrite is a write to one of the memory bank controller registers
+type RegisterWrite struct {
+	Address uint16
+	Value   byte
+}
+
+const RAMBankSize = 0x2000
+
+// https://gbdev.io/pandocs/The_Cartridge_Header.html#0149---ram-size
+var ramSizes = map[byte]int{
+	0x00: 0,
+	0x01: 0x800,
+	0x02: 1 * RAMBankSize,
+	0x03: 4 * RAMBankSize,
+	0x04: 16 * RAMBankSize,
+	0x05: 8 * RAMBankSize,
+}
+
+// romOnly has no banking, with an optional 8KiB of ram
+// https://gbdev.io/pandocs/nombc.html
+type romOnly struct {
+	rom []byte
+	ram []byte
+}
+
+func (m *romOnly) Read(address uint16) byte {
+	if address < 0x8000 {
+		if int(address) >= len(m.rom) {
+			return 0xFF
+		}
+		return m.rom[address]
+	}
+
+	return readRAM(m.ram, int(address-0xA000))
+}
+
+func (m *romOnly) Write(address uint16, value byte) {
+	if address >= 0xA000 {
+		writeRAM(m.ram, int(address-0xA000), value)
+	}
+}
+
+func (m *romOnly) ROMBank() int {
+	return 1
+}
+
+func (m *romOnly) state() []byte {
+	return nil
+}
+
+func (m *romOnly) registers() []RegisterWrite {
+	return nil
+}
+
+func (m *romOnly) setState(data []byte) error {
+	if len(data) != 0 {
+		return errs.NewInvalidStateError("rom only", len(data))
+	}
+	return nil
+}
+
+// https://gbdev.io/pandocs/MBC1.html
+type mbc1 struct {
+	rom []byte
+	ram []byte
+	// 0000-1FFF: ram enable, 0x0A in the lower nibble enables
+	ramEnabled bool
+	// 2000-3FFF: lower 5 bits of the rom bank
+	bank1 byte
+	// 4000-5FFF: upper 2 bits of the rom bank, or the ram bank
+	bank2 byte
+	// 6000-7FFF: banking mode select
+	mode byte
+}
+
+func newMBC1(rom, ram []byte) *mbc1 {
+	return &mbc1{
+		rom:   rom,
+		ram:   ram,
+		bank1: 1,
+	}
+}
+
+func (m *mbc1) Read(address uint16) byte {
+	switch {
+	case address < 0x4000:
+		bank := 0
+		if m.mode == 1 {
+			bank = int(m.bank2) << 5
+		}
+		return readROM(m.rom, bank, address)
+	case address < 0x8000:
+		return readROM(m.rom, m.ROMBank(), address)
+	default:
+		if !m.ramEnabled {
+			return 0xFF
+		}
+		return readRAM(m.ram, m.ramOffset(address))
+	}
+}
+
+func (m *mbc1) Write(address uint16, value byte) {
+	switch {
+	case address < 0x2000:
+		m.ramEnabled = value&0x0F == 0x0A
+	case address < 0x4000:
+		m.bank1 = value & 0x1F
+		// bank 0 can't be selected, it's translated to bank 1
+		if m.bank1 == 0 {
+			m.bank1 = 1
+		}
+	case address < 0x6000:
+		m.bank2 = value & 0x03
+	case address < 0x8000:
+		m.mode = value & 0x01
+	default:
+		if m.ramEnabled {
+			writeRAM(m.ram, m.ramOffset(address), value)
+		}
+	}
+}
+
+func (m *mbc1) ROMBank() int {
+	return int(m.bank2)<<5 | int(m.bank1)
+}
+
+func (m *mbc1) state() []byte {
+	enabled := byte(0)
+	if m.ramEnabled {
+		enabled = 1
+	}
+
+	return []byte{enabled, m.bank1, m.bank2, m.mode}
+}
+
+func (m *mbc1) setState(data []byte) error {
+	if len(data) != 4 {
+		return errs.NewInvalidStateError("mbc1", len(data))
+	}
+
+	m.ramEnabled = data[0] != 0
+	m.bank1, m.bank2, m.mode = data[1], data[2], data[3]
+	return nil
+}
+
+func (m *mbc1) registers() []RegisterWrite {
+	enable := byte(0x00)
+	if m.ramEnabled {
+		enable = 0x0A
+	}
+
+	return []RegisterWrite{
+		{0x0000, enable},
+		{0x2000, m.bank1},
+		{0x4000, m.bank2},
+		{0x6000, m.mode},
+	}
+}
+
+func (m *mbc1) ramOffset(address uint16) int {
+	bank := 0
+	if m.mode == 1 {
+		bank = int(m.bank2)
+	}
+
+	return bank*RAMBankSize + int(address-0xA000)
+}
+
+// readROM reads from a bank, wrapping the bank number to the rom size like the real hardware
+func readROM(rom []byte, bank int, address uint16) byte {
+	banks := (len(rom) + ROMBankSize - 1) / ROMBankSize
+	offset := (bank%banks)*ROMBankSize + int(address%ROMBankSize)
+	if offset >= len(rom) {
+		return 0xFF
+	}
+
+	return rom[offset]
+}
+
+// readRAM reads from cartridge ram, open bus reads as 0xFF when there's no ram
+func readRAM(ram []byte, offset int) byte {
+	if len(ram) == 0 {
+		return 0xFF
+	}
+
+	return ram[offset%len(ram)]
+}
+
+func writeRAM(ram []byte, offset int, value byte) {
+	if len(ram) == 0 {
+		return
+	}
+
+	ram[offset%len(ram)] = value
+}
+
+// https://gbdev.io/pandocs/MBC2.html
+type mbc2 struct {
+	rom []byte
+	// 512 half bytes built into the mbc, mirrored through 0xA000-0xBFFF
+	ram        []byte
+	ramEnabled bool
+	bank       byte
+}
+
+// MBC2RAMSize is the size of the ram built into MBC2, the header says there's none
+const MBC2RAMSize = 0x200
+
+func newMBC2(rom, ram []byte) *mbc2 {
+	return &mbc2{
+		rom:  rom,
+		ram:  ram,
+		bank: 1,
+	}
+}
+
+func (m *mbc2) Read(address uint16) byte {
+	switch {
+	case address < 0x4000:
+		return readROM(m.rom, 0, address)
+	case address < 0x8000:
+		return readROM(m.rom, m.ROMBank(), address)
+	default:
+		if !m.ramEnabled {
+			return 0xFF
+		}
+		// only the lower nibble exists, the upper one reads as 1s
+		return 0xF0 | m.ram[address&0x1FF]
+	}
+}
+
+func (m *mbc2) Write(address uint16, value byte) {
+	switch {
+	case address < 0x4000:
+		// bit 8 of the address picks the register
+		if address&0x100 == 0 {
+			m.ramEnabled = value&0x0F == 0x0A
+			return
+		}
+
+		m.bank = value & 0x0F
+		if m.bank == 0 {
+			m.bank = 1
+		}
+	case address < 0x8000:
+		// nothing is mapped here
+	default:
+		if m.ramEnabled {
+			m.ram[address&0x1FF] = value & 0x0F
+		}
+	}
+}
+
+func (m *mbc2) ROMBank() int {
+	return int(m.bank)
+}
+
+func (m *mbc2) state() []byte {
+	return []byte{boolByte(m.ramEnabled), m.bank}
+}
+
+func (m *mbc2) setState(data []byte) error {
+	if len(data) != 2 {
+		return errs.NewInvalidStateError("mbc2", len(data))
+	}
+
+	m.ramEnabled, m.bank = data[0] != 0, data[1]
+	return nil
+}
+
+func (m *mbc2) registers() []RegisterWrite {
+	enable := byte(0x00)
+	if m.ramEnabled {
+		enable = 0x0A
+	}
+
+	return []RegisterWrite{
+		{0x0000, enable},
+		{0x0100, m.bank},
+	}
+}
+
+// https://gbdev.io/pandocs/MBC3.html
+type mbc3 struct {
+	rom []byte
+	ram []byte
+	// clock is nil for cartridges without a timer
+	clock *rtc
+	// 0000-1FFF: ram and clock enable
+	ramEnabled bool
+	// 2000-3FFF: 7 bit rom bank
+	bank byte
+	// 4000-5FFF: ram bank 0-3, or clock register 0x08-0x0C
+	selected byte
+	// 6000-7FFF: the last value written, writing 0x00 then 0x01 latches the clock
+	latch byte
+}
+
+func newMBC3(rom, ram []byte, clock *rtc) *mbc3 {
+	return &mbc3{
+		rom:   rom,
+		ram:   ram,
+		clock: clock,
+		bank:  1,
+		latch: 0xFF,
+	}
+}
+
+func (m *mbc3) Read(address uint16) byte {
+	switch {
+	case address < 0x4000:
+		return readROM(m.rom, 0, address)
+	case address < 0x8000:
+		return readROM(m.rom, m.ROMBank(), address)
+	default:
+		if !m.ramEnabled {
+			return 0xFF
+		}
+		if register, ok := m.clockRegister(); ok {
+			return m.clock.latched[register]
+		}
+		if m.selected > 0x03 {
+			return 0xFF
+		}
+		return readRAM(m.ram, int(m.selected)*RAMBankSize+int(address-0xA000))
+	}
+}
+
+func (m *mbc3) Write(address uint16, value byte) {
+	switch {
+	case address < 0x2000:
+		m.ramEnabled = value&0x0F == 0x0A
+	case address < 0x4000:
+		m.bank = value & 0x7F
+		if m.bank == 0 {
+			m.bank = 1
+		}
+	case address < 0x6000:
+		m.selected = value
+	case address < 0x8000:
+		if m.clock != nil && m.latch == 0x00 && value == 0x01 {
+			m.clock.latch()
+		}
+		m.latch = value
+	default:
+		if !m.ramEnabled {
+			return
+		}
+		if register, ok := m.clockRegister(); ok {
+			m.clock.write(register, value)
+			return
+		}
+		if m.selected <= 0x03 {
+			writeRAM(m.ram, int(m.selected)*RAMBankSize+int(address-0xA000), value)
+		}
+	}
+}
+
+// clockRegister returns the clock register mapped into 0xA000-0xBFFF, if one is
+func (m *mbc3) clockRegister() (int, bool) {
+	if m.clock == nil || m.selected < 0x08 || m.selected > 0x0C {
+		return 0, false
+	}
+
+	return int(m.selected - 0x08), true
+}
+
+func (m *mbc3) ROMBank() int {
+	return int(m.bank)
+}
+
+func (m *mbc3) state() []byte {
+	data := []byte{boolByte(m.ramEnabled), m.bank, m.selected, m.latch}
+	if m.clock != nil {
+		data = append(data, m.clock.state()...)
+	}
+	return data
+}
+
+func (m *mbc3) setState(data []byte) error {
+	if len(data) < 4 || (m.clock == nil) != (len(data) == 4) {
+		return errs.NewInvalidStateError("mbc3", len(data))
+	}
+
+	if m.clock != nil {
+		if err := m.clock.setState(data[4:]); err != nil {
+			return err
+		}
+	}
+
+	m.ramEnabled = data[0] != 0
+	m.bank, m.selected, m.latch = data[1], data[2], data[3]
+	return nil
+}
+
+func (m *mbc3) registers() []RegisterWrite {
+	enable := byte(0x00)
+	if m.ramEnabled {
+		enable = 0x0A
+	}
+
+	return []RegisterWrite{
+		{0x0000, enable},
+		{0x2000, m.bank},
+		{0x4000, m.selected},
+	}
+}
+
+// https://gbdev.io/pandocs/MBC5.html
+type mbc5 struct {
+	rom []byte
+	ram []byte
+	// rumble cartridges use bit 3 of the ram bank for the motor
+	rumble     bool
+	ramEnabled bool
+	// 2000-2FFF: lower 8 bits of the rom bank, 3000-3FFF: the 9th bit
+	bank uint16
+	// 4000-5FFF: ram bank 0-15
+	ramBank byte
+}
+
+func newMBC5(rom, ram []byte, rumble bool) *mbc5 {
+	return &mbc5{
+		rom:    rom,
+		ram:    ram,
+		rumble: rumble,
+		bank:   1,
+	}
+}
+
+func (m *mbc5) Read(address uint16) byte {
+	switch {
+	case address < 0x4000:
+		return readROM(m.rom, 0, address)
+	case address < 0x8000:
+		return readROM(m.rom, m.ROMBank(), address)
+	default:
+		if !m.ramEnabled {
+			return 0xFF
+		}
+		return readRAM(m.ram, int(m.ramBank)*RAMBankSize+int(address-0xA000))
+	}
+}
+
+func (m *mbc5) Write(address uint16, value byte) {
+	switch {
+	case address < 0x2000:
+		m.ramEnabled = value&0x0F == 0x0A
+	case address < 0x3000:
+		// unlike the other mbcs, bank 0 can be switched in
+		m.bank = m.bank&0x100 | uint16(value)
+	case address < 0x4000:
+		m.bank = m.bank&0xFF | uint16(value&0x01)<<8
+	case address < 0x6000:
+		m.ramBank = value & 0x0F
+		if m.rumble {
+			m.ramBank &= 0x07
+		}
+	case address < 0x8000:
+		// nothing is mapped here
+	default:
+		if m.ramEnabled {
+			writeRAM(m.ram, int(m.ramBank)*RAMBankSize+int(address-0xA000), value)
+		}
+	}
+}
+
+func (m *mbc5) ROMBank() int {
+	return int(m.bank)
+}
+
+func (m *mbc5) state() []byte {
+	return []byte{boolByte(m.ramEnabled), byte(m.bank), byte(m.bank >> 8), m.ramBank}
+}
+
+func (m *mbc5) setState(data []byte) error {
+	if len(data) != 4 {
+		return errs.NewInvalidStateError("mbc5", len(data))
+	}
+
+	m.ramEnabled = data[0] != 0
+	m.bank = uint16(data[1]) | uint16(data[2]&0x01)<<8
+	m.ramBank = data[3]
+	return nil
+}
+
+func (m *mbc5) registers() []RegisterWrite {
+	enable := byte(0x00)
+	if m.ramEnabled {
+		enable = 0x0A
+	}
+
+	return []RegisterWrite{
+		{0x0000, enable},
+		{0x2000, byte(m.bank)},
+		{0x3000, byte(m.bank >> 8)},
+		{0x4000, m.ramBank},
+	}
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,168 @@
+package cartridge_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBankedCartridge creates a cartridge with the number of rom banks, each starting with its
+// bank number (low byte, then high byte)
+func newBankedCartridge(t *testing.T, cartType cartridge.CartridgeType, banks int, ramSize byte) *cartridge.Cartridge {
+	rom := make([]byte, banks*cartridge.ROMBankSize)
+	for bank := 0; bank < banks; bank++ {
+		rom[bank*cartridge.ROMBankSize] = byte(bank)
+		rom[bank*cartridge.ROMBankSize+1] = byte(bank >> 8)
+	}
+	rom[0x147] = byte(cartType)
+	rom[0x149] = ramSize
+
+	cart, err := cartridge.FromBytes(rom)
+	require.NoError(t, err)
+	require.True(t, cart.Supported())
+	return cart
+}
+
+func bank(cart *cartridge.Cartridge) int {
+	return int(cart.Read(0x4000)) | int(cart.Read(0x4001))<<8
+}
+
+func TestMBC2(t *testing.T) {
+	cart := newBankedCartridge(t, cartridge.MBC2_BATTERY, 16, 0)
+	assert.Len(t, cart.RAM, cartridge.MBC2RAMSize)
+
+	// bit 8 of the address picks the rom bank register
+	cart.Write(0x2100, 0x05)
+	assert.Equal(t, 5, bank(cart))
+	cart.Write(0x2100, 0x00)
+	assert.Equal(t, 1, bank(cart))
+
+	assert.Equal(t, byte(0xFF), cart.Read(0xA000), "ram is disabled")
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0xA001, 0xAB)
+	assert.Equal(t, byte(0xFB), cart.Read(0xA001), "only the lower nibble is stored")
+	assert.Equal(t, byte(0xFB), cart.Read(0xA201), "mirrored every 512 bytes")
+}
+
+func TestMBC3(t *testing.T) {
+	cart := newBankedCartridge(t, cartridge.MBC3_RAM_BATTERY, 128, 0x03)
+	assert.False(t, cart.HasClock())
+
+	cart.Write(0x2000, 0x7F)
+	assert.Equal(t, 127, bank(cart))
+	cart.Write(0x2000, 0x00)
+	assert.Equal(t, 1, bank(cart))
+
+	cart.Write(0x0000, 0x0A)
+	for ramBank := byte(0); ramBank < 4; ramBank++ {
+		cart.Write(0x4000, ramBank)
+		cart.Write(0xA000, 0x10+ramBank)
+	}
+	cart.Write(0x4000, 0x02)
+	assert.Equal(t, byte(0x12), cart.Read(0xA000))
+
+	// no clock to select
+	cart.Write(0x4000, 0x08)
+	assert.Equal(t, byte(0xFF), cart.Read(0xA000))
+}
+
+// selectClock enables the clock and maps one of its registers into 0xA000
+func selectClock(cart *cartridge.Cartridge, register byte) {
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0x4000, 0x08+register)
+}
+
+func latch(cart *cartridge.Cartridge) {
+	cart.Write(0x6000, 0x00)
+	cart.Write(0x6000, 0x01)
+}
+
+func TestMBC3Clock(t *testing.T) {
+	cart := newBankedCartridge(t, cartridge.MBC3_TIMER_RAM_BATTERY, 4, 0x03)
+	require.True(t, cart.HasClock())
+
+	// a second of M-cycles
+	for i := 0; i < 4194304/4; i++ {
+		cart.Tick()
+	}
+
+	selectClock(cart, 0)
+	assert.Equal(t, byte(0), cart.Read(0xA000), "not latched yet")
+	latch(cart)
+	assert.Equal(t, byte(1), cart.Read(0xA000))
+
+	// 23:59:59 on day 511 rolls over to day 0 with the carry set
+	for register, value := range []byte{59, 59, 23, 0xFF, 0x01} {
+		selectClock(cart, byte(register))
+		cart.Write(0xA000, value)
+	}
+	cart.SetRTC(cart.RTC(time.Unix(100, 0)), time.Unix(101, 0))
+	latch(cart)
+
+	want := []byte{0, 0, 0, 0, 0x80}
+	for register := range want {
+		selectClock(cart, byte(register))
+		assert.Equal(t, want[register], cart.Read(0xA000), "register %d", register)
+	}
+
+	// halted clocks don't count
+	selectClock(cart, 4)
+	cart.Write(0xA000, 0x40)
+	cart.SetRTC(cart.RTC(time.Unix(100, 0)), time.Unix(200, 0))
+	latch(cart)
+	selectClock(cart, 0)
+	assert.Equal(t, byte(0), cart.Read(0xA000))
+
+	assert.Equal(t, int64(0), cart.Clock())
+	cart.SetClock(90061)
+	assert.Equal(t, int64(90061), cart.Clock())
+}
+
+func TestMBC5(t *testing.T) {
+	cart := newBankedCartridge(t, cartridge.MBC5_RAM, 512, 0x04)
+
+	cart.Write(0x2000, 0x34)
+	cart.Write(0x3000, 0x01)
+	assert.Equal(t, 0x134, bank(cart))
+
+	// bank 0 can be switched in
+	cart.Write(0x3000, 0x00)
+	cart.Write(0x2000, 0x00)
+	assert.Equal(t, 0, bank(cart))
+
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0x4000, 0x0F)
+	cart.Write(0xA000, 0x42)
+	cart.Write(0x4000, 0x00)
+	assert.NotEqual(t, byte(0x42), cart.Read(0xA000))
+	cart.Write(0x4000, 0x0F)
+	assert.Equal(t, byte(0x42), cart.Read(0xA000))
+}
+
+func TestUnsupported(t *testing.T) {
+	rom := make([]byte, 4*cartridge.ROMBankSize)
+	rom[0x147] = byte(cartridge.HUC3)
+
+	cart, err := cartridge.FromBytes(rom)
+	require.NoError(t, err)
+	assert.False(t, cart.Supported())
+	assert.Equal(t, byte(0x00), cart.Read(0x4000))
+}
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	cart := newBankedCartridge(t, cartridge.MBC3_TIMER_RAM_BATTERY, 8, 0x03)
+	cart.Write(0x2000, 0x05)
+	selectClock(cart, 1)
+	cart.Write(0xA000, 30)
+
+	data, err := cart.MarshalBinary()
+	require.NoError(t, err)
+
+	other := newBankedCartridge(t, cartridge.MBC3_TIMER_RAM_BATTERY, 8, 0x03)
+	require.NoError(t, other.UnmarshalBinary(data))
+	assert.Equal(t, 5, bank(other))
+	assert.Equal(t, int64(30*60), other.Clock())
+}
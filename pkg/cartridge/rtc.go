@@ -0,0 +1,196 @@
+package cartridge
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	errs "github.com/robherley/go-gameboy/pkg/errors"
+)
+
+// https://gbdev.io/pandocs/MBC3.html#the-clock-counter-registers
+
+// the clock registers, in the order they're selected with 0x08-0x0C
+const (
+	rtcSeconds = iota
+	rtcMinutes
+	rtcHours
+	rtcDaysLow
+	// bit 0 is the 9th bit of the day counter, bit 6 halts the clock and bit 7 is the day carry
+	rtcDaysHigh
+)
+
+const (
+	rtcHalt  = 0x40
+	rtcCarry = 0x80
+
+	// the clock is ticked every M-cycle, 4194304 T-cycles a second
+	rtcCyclesPerSecond = 4194304 / 4
+
+	// RTCSize is the length of the clock at the end of a save or in a BESS RTC block: the current
+	// and latched registers as little endian uint32s, then the unix time it was written
+	// https://bgb.bircd.org/rtcsave.html
+	RTCSize = 0x30
+)
+
+// rtc is the real time clock in MBC3 cartridges. It counts emulated time, so it's deterministic
+// while running and only catches up with the wall clock when it's loaded from a save
+type rtc struct {
+	regs [5]byte
+	// latched is a copy of regs that the game reads, so they don't change while it's reading them
+	latched [5]byte
+	// M-cycles into the current second
+	cycles uint32
+}
+
+func (r *rtc) halted() bool {
+	return r.regs[rtcDaysHigh]&rtcHalt != 0
+}
+
+func (r *rtc) tick() {
+	if r.halted() {
+		return
+	}
+
+	r.cycles++
+	if r.cycles == rtcCyclesPerSecond {
+		r.cycles = 0
+		r.second()
+	}
+}
+
+// write sets one of the registers, only the bits the hardware has are kept
+func (r *rtc) write(register int, value byte) {
+	masks := [5]byte{0x3F, 0x3F, 0x1F, 0xFF, 0xC1}
+	r.regs[register] = value & masks[register]
+
+	// writing the seconds resets the sub-second counter
+	if register == rtcSeconds {
+		r.cycles = 0
+	}
+}
+
+func (r *rtc) latch() {
+	r.latched = r.regs
+}
+
+func (r *rtc) days() int {
+	return int(r.regs[rtcDaysLow]) | int(r.regs[rtcDaysHigh]&0x01)<<8
+}
+
+func (r *rtc) setDays(days int) {
+	if days > 0x1FF {
+		days &= 0x1FF
+		r.regs[rtcDaysHigh] |= rtcCarry
+	}
+
+	r.regs[rtcDaysLow] = byte(days)
+	r.regs[rtcDaysHigh] = r.regs[rtcDaysHigh]&^0x01 | byte(days>>8)
+}
+
+// second counts up a second. Out of range values (ie: 60 seconds, written by the game) keep
+// counting until they overflow the register's bits, without carrying
+func (r *rtc) second() {
+	if r.regs[rtcSeconds] = (r.regs[rtcSeconds] + 1) & 0x3F; r.regs[rtcSeconds] != 60 {
+		return
+	}
+	r.regs[rtcSeconds] = 0
+
+	if r.regs[rtcMinutes] = (r.regs[rtcMinutes] + 1) & 0x3F; r.regs[rtcMinutes] != 60 {
+		return
+	}
+	r.regs[rtcMinutes] = 0
+
+	if r.regs[rtcHours] = (r.regs[rtcHours] + 1) & 0x1F; r.regs[rtcHours] != 24 {
+		return
+	}
+	r.regs[rtcHours] = 0
+
+	r.setDays(r.days() + 1)
+}
+
+// advance counts up the seconds at once, for time that passed while the emulator wasn't running
+func (r *rtc) advance(seconds uint64) {
+	if r.halted() {
+		return
+	}
+
+	// out of range values take a few seconds (at most a few hours) to wrap around into range
+	for seconds > 0 && (r.regs[rtcSeconds] > 59 || r.regs[rtcMinutes] > 59 || r.regs[rtcHours] > 23) {
+		r.second()
+		seconds--
+	}
+
+	total := seconds + r.seconds()
+	r.regs[rtcSeconds] = byte(total % 60)
+	r.regs[rtcMinutes] = byte(total / 60 % 60)
+	r.regs[rtcHours] = byte(total / 3600 % 24)
+
+	days := total / 86400
+	if days > 0x1FF {
+		r.regs[rtcDaysHigh] |= rtcCarry
+	}
+	r.setDays(int(days % 0x200))
+}
+
+// seconds is the time on the clock, without the carry
+func (r *rtc) seconds() uint64 {
+	return uint64(r.regs[rtcSeconds]) + 60*uint64(r.regs[rtcMinutes]) + 3600*uint64(r.regs[rtcHours]) + 86400*uint64(r.days())
+}
+
+// marshal encodes the registers and the time they were saved at, see RTCSize
+func (r *rtc) marshal(now time.Time) []byte {
+	data := make([]byte, RTCSize)
+	for i := range r.regs {
+		binary.LittleEndian.PutUint32(data[4*i:], uint32(r.regs[i]))
+		binary.LittleEndian.PutUint32(data[20+4*i:], uint32(r.latched[i]))
+	}
+	binary.LittleEndian.PutUint64(data[40:], uint64(now.Unix()))
+
+	return data
+}
+
+// unmarshal restores the registers, then catches up with the time since they were saved. Some
+// emulators save the time as a uint32, 4 bytes short of RTCSize
+func (r *rtc) unmarshal(data []byte, now time.Time) error {
+	var saved int64
+	switch len(data) {
+	case RTCSize:
+		saved = int64(binary.LittleEndian.Uint64(data[40:]))
+	case RTCSize - 4:
+		saved = int64(binary.LittleEndian.Uint32(data[40:]))
+	default:
+		return fmt.Errorf("%w: clock is %d bytes, want %d", errs.ErrorInvalidState, len(data), RTCSize)
+	}
+
+	for i := range r.regs {
+		r.write(i, byte(binary.LittleEndian.Uint32(data[4*i:])))
+		r.latched[i] = byte(binary.LittleEndian.Uint32(data[20+4*i:]))
+	}
+
+	if elapsed := now.Unix() - saved; elapsed > 0 {
+		r.advance(uint64(elapsed))
+	}
+
+	return nil
+}
+
+// state and setState save and restore the clock for save states, which don't catch up
+func (r *rtc) state() []byte {
+	data := make([]byte, 14)
+	copy(data, r.regs[:])
+	copy(data[5:], r.latched[:])
+	binary.LittleEndian.PutUint32(data[10:], r.cycles)
+	return data
+}
+
+func (r *rtc) setState(data []byte) error {
+	if len(data) != 14 {
+		return errs.NewInvalidStateError("rtc", len(data))
+	}
+
+	copy(r.regs[:], data[:5])
+	copy(r.latched[:], data[5:10])
+	r.cycles = binary.LittleEndian.Uint32(data[10:])
+	return nil
+}
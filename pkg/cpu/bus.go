@@ -0,0 +1,36 @@
+package cpu
+
+import "github.com/robherley/go-gameboy/pkg/interrupt"
+
+// Bus is what the CPU is connected to, the MMU on a Game Boy. Any SM83 system
+// (or a flat test ram) can be driven by providing a different Bus.
+type Bus interface {
+	Read8(address uint16) byte
+	Write8(address uint16, data byte)
+	// Tick advances everything else on the bus by one M-cycle (4 T-cycles)
+	Tick()
+}
+
+// Option configures a CPU in New
+type Option func(*CPU)
+
+// WithBus connects the CPU to the bus instead of creating an MMU
+func WithBus(bus Bus) Option {
+	return func(cpu *CPU) {
+		cpu.Bus = bus
+	}
+}
+
+// WithRegisters sets the initial registers instead of the DMG power up state
+func WithRegisters(registers *Registers) Option {
+	return func(cpu *CPU) {
+		cpu.Registers = registers
+	}
+}
+
+// WithInterrupt shares the interrupt state with the bus, which maps the IF and IE registers
+func WithInterrupt(inter *interrupt.Interrupt) Option {
+	return func(cpu *CPU) {
+		cpu.Interrupt = inter
+	}
+}
@@ -2,74 +2,107 @@ package cpu
 
 import (
 	"github.com/robherley/go-gameboy/internal/bits"
+	"github.com/robherley/go-gameboy/pkg/apu"
 	"github.com/robherley/go-gameboy/pkg/cartridge"
 	errs "github.com/robherley/go-gameboy/pkg/errors"
 	"github.com/robherley/go-gameboy/pkg/interrupt"
+	"github.com/robherley/go-gameboy/pkg/joypad"
 	"github.com/robherley/go-gameboy/pkg/mmu"
+	"github.com/robherley/go-gameboy/pkg/ppu"
 	"github.com/robherley/go-gameboy/pkg/timer"
 )
 
 type CPU struct {
 	Registers *Registers
-	MMU       *mmu.MMU
+	// Bus is used for every memory access, by default it's the MMU
+	Bus       Bus
 	Interrupt *interrupt.Interrupt
-	Timer     *timer.Timer
 	Halted    bool
-	Ticks     uint64
+	// Locked is set after an illegal opcode, the cpu stops until it's reset and interrupts can't wake it
+	Locked bool
+	// Strict panics on illegal opcodes instead of locking up
+	Strict bool
+	Ticks  uint64
 }
 
+// New creates a CPU in the DMG power up state, connected to an MMU for the cartridge.
+// The cartridge may be nil if both the bus and registers are provided with options.
 // https://gbdev.io/pandocs/Power_Up_Sequence.html
-func New(cart *cartridge.Cartridge) *CPU {
-	inter := interrupt.New()
-	time := timer.New(func() {
-		inter.Flag |= byte(interrupt.TIMER)
-	})
-
-	return &CPU{
-		Registers: RegistersForDMG(cart),
-		Timer:     time,
-		MMU: mmu.New(
+func New(cart *cartridge.Cartridge, opts ...Option) *CPU {
+	cpu := &CPU{
+		Halted: false,
+	}
+
+	for _, opt := range opts {
+		opt(cpu)
+	}
+
+	if cpu.Interrupt == nil {
+		cpu.Interrupt = interrupt.New()
+	}
+
+	if cpu.Registers == nil {
+		cpu.Registers = RegistersForDMG(cart)
+	}
+
+	if cpu.Bus == nil {
+		inter := cpu.Interrupt
+		time := timer.New(func() {
+			inter.Flag |= byte(interrupt.TIMER)
+		})
+		pad := joypad.New(func() {
+			inter.Flag |= byte(interrupt.JOYPAD)
+		})
+		video := ppu.New(func() {
+			inter.Flag |= byte(interrupt.VBLANK)
+		}, func() {
+			inter.Flag |= byte(interrupt.LCD_STAT)
+		})
+
+		cpu.Bus = mmu.New(
 			cart,
 			inter,
 			time,
-		),
-		Interrupt: inter,
-		Halted:    false,
+			pad,
+			video,
+			apu.New(),
+		)
 	}
+
+	return cpu
 }
 
+// EmulateCycles advances the rest of the system by the number of M-cycles
 func (cpu *CPU) EmulateCycles(cycles int) {
-	n := cycles * 4
-	for i := 0; i < n; i++ {
-		cpu.Ticks++
-		cpu.Timer.Tick()
+	for i := 0; i < cycles; i++ {
+		cpu.Ticks += 4
+		cpu.Bus.Tick()
 	}
 }
 
 func (cpu *CPU) Read8(address uint16) byte {
-	val := cpu.MMU.Read8(address)
+	val := cpu.Bus.Read8(address)
 	cpu.EmulateCycles(1)
 	return val
 }
 
 func (cpu *CPU) Read16(address uint16) uint16 {
-	val := cpu.MMU.Read16(address)
+	lo := cpu.Bus.Read8(address)
+	hi := cpu.Bus.Read8(address + 1)
+	val := bits.To16(hi, lo)
 	cpu.EmulateCycles(1)
 	return val
 }
 
 func (cpu *CPU) Write8(address uint16, data byte) {
-	if address == interrupt.FLAG_ADDRESS || address == timer.TAC_ADDRESS || address == timer.TIMA_ADDRESS {
-		func() {}()
-	}
-
 	cpu.EmulateCycles(1)
-	cpu.MMU.Write8(address, data)
+	cpu.Bus.Write8(address, data)
 }
 
 func (cpu *CPU) Write16(address uint16, data uint16) {
 	cpu.EmulateCycles(2)
-	cpu.MMU.Write16(address, data)
+	cpu.Bus.Write8(address, bits.Lo(data))
+	cpu.Bus.Write8(address+1, bits.Hi(data))
 }
 
 func (cpu *CPU) Fetch8() byte {
@@ -133,7 +166,7 @@ func (cpu *CPU) Get(operand *Operand) uint16 {
 	switch symbol := operand.Symbol.(type) {
 	case Register:
 		if operand.Deref {
-			return uint16(cpu.Read8(val))
+			return uint16(cpu.Read8(derefAddress(symbol, val)))
 		}
 		return val
 	case Address, Data, Byte:
@@ -162,7 +195,7 @@ func (cpu *CPU) Set(operand *Operand, val uint16) {
 	switch symbol := operand.Symbol.(type) {
 	case Register:
 		if operand.Deref {
-			addr := cpu.Registers.Get(symbol)
+			addr := derefAddress(symbol, cpu.Registers.Get(symbol))
 			writeFunc(addr, val)
 		} else {
 			cpu.Registers.Set(symbol, val)
@@ -175,6 +208,16 @@ func (cpu *CPU) Set(operand *Operand, val uint16) {
 	}
 }
 
+// derefAddress returns the address a dereferenced register points to.
+// (C) is the only 8-bit register deref, it's relative to 0xFF00 (see 0xE2 and 0xF2)
+func derefAddress(reg Register, val uint16) uint16 {
+	if reg == C {
+		return 0xFF00 | val
+	}
+
+	return val
+}
+
 func (cpu *CPU) HandleInterrupts() {
 	// check if master flag should be enabled this cycle
 	if cpu.Interrupt.EI != interrupt.MASTER_SET_NONE {
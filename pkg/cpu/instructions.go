@@ -1,20 +1,34 @@
 package cpu
 
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
 // https://gbdev.io/pandocs/CPU_Instruction_Set.html
 // https://gbdev.io/gb-opcodes/optables/
 // instructions generated from: https://gbdev.io/gb-opcodes/Opcodes.json
 // script: https://gist.github.com/robherley/836369cbd8eb73a286d017626b8376c1
 
 type Instruction struct {
-	operation Operation
-	operands  []Operand
+	Operation Operation
+	Operands  []Operand
 }
 
 func (i *Instruction) Execute(cpu *CPU) {
-	i.operation(cpu, i.operands)
+	i.Operation(cpu, i.Operands)
 	cpu.EmulateCycles(1)
 }
 
+// Mnemonic returns the name of the instruction's operation, ie: LD, JP, ILLEGAL_D3
+func (i *Instruction) Mnemonic() string {
+	// not the most efficient, but the operation funcs are named after their mnemonic
+	funcName := runtime.FuncForPC(reflect.ValueOf(i.Operation).Pointer()).Name()
+	split := strings.Split(funcName, ".")
+	return split[len(split)-1]
+}
+
 func InstructionFromOPCode(code byte, cbprefix bool) *Instruction {
 	var mapping map[byte]Instruction
 	if cbprefix {
@@ -379,7 +393,7 @@ var unprefixed = map[byte]Instruction{
 	0x38: {
 		JR,
 		[]Operand{
-			{Symbol: C},
+			{Symbol: Ca},
 			{Symbol: R8},
 		},
 	},
@@ -1428,7 +1442,7 @@ var unprefixed = map[byte]Instruction{
 	0xD8: {
 		RET,
 		[]Operand{
-			{Symbol: C},
+			{Symbol: Ca},
 		},
 	},
 	0xD9: {
@@ -1449,7 +1463,7 @@ var unprefixed = map[byte]Instruction{
 	0xDC: {
 		CALL,
 		[]Operand{
-			{Symbol: C},
+			{Symbol: Ca},
 			{Symbol: A16},
 		},
 	},
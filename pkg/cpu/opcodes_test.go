@@ -0,0 +1,166 @@
+package cpu_test
+
+import (
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/cpu"
+	"github.com/stretchr/testify/assert"
+)
+
+const carry = 0x10
+
+// regressions for opcodes the single step tests flagged, which aren't checked in
+
+type opcodeCase struct {
+	name    string
+	program []byte
+	setup   func(c *cpu.CPU, bus *flatBus)
+	check   func(t *testing.T, c *cpu.CPU, bus *flatBus)
+}
+
+func runOpcodeCases(t *testing.T, cases []opcodeCase) {
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bus := &flatBus{}
+			c := cpu.New(nil, cpu.WithBus(bus), cpu.WithRegisters(&cpu.Registers{PC: 0x100, SP: 0xFFFE}))
+			copy(bus.memory[0x100:], tc.program)
+			if tc.setup != nil {
+				tc.setup(c, bus)
+			}
+
+			_, instruction := c.NextInstruction()
+			instruction.Execute(c)
+			tc.check(t, c, bus)
+		})
+	}
+}
+
+func TestSBC(t *testing.T) {
+	runOpcodeCases(t, []opcodeCase{
+		{
+			name:    "SBC A,B with carry",
+			program: []byte{0x98},
+			setup: func(c *cpu.CPU, bus *flatBus) {
+				c.Registers.A, c.Registers.B, c.Registers.F = 0x10, 0x01, carry
+			},
+			check: func(t *testing.T, c *cpu.CPU, bus *flatBus) {
+				assert.Equal(t, byte(0x0E), c.Registers.A)
+				assert.Equal(t, "-NH- (0x60)", flagString(c.Registers.F))
+			},
+		},
+		{
+			name:    "SBC A,B borrows through the carry",
+			program: []byte{0x98},
+			setup: func(c *cpu.CPU, bus *flatBus) {
+				c.Registers.A, c.Registers.B, c.Registers.F = 0x00, 0xFF, carry
+			},
+			check: func(t *testing.T, c *cpu.CPU, bus *flatBus) {
+				assert.Equal(t, byte(0x00), c.Registers.A)
+				assert.Equal(t, "ZNHC (0xF0)", flagString(c.Registers.F))
+			},
+		},
+		{
+			name:    "SBC A,d8",
+			program: []byte{0xDE, 0x01},
+			setup: func(c *cpu.CPU, bus *flatBus) {
+				c.Registers.A = 0x05
+			},
+			check: func(t *testing.T, c *cpu.CPU, bus *flatBus) {
+				assert.Equal(t, byte(0x04), c.Registers.A)
+				assert.Equal(t, "-N-- (0x40)", flagString(c.Registers.F))
+				assert.Equal(t, uint16(0x102), c.Registers.PC)
+			},
+		},
+	})
+}
+
+func TestLoadSPToAddress(t *testing.T) {
+	runOpcodeCases(t, []opcodeCase{
+		{
+			name:    "LD (a16),SP",
+			program: []byte{0x08, 0x00, 0xC0},
+			setup: func(c *cpu.CPU, bus *flatBus) {
+				c.Registers.SP = 0xBEEF
+			},
+			check: func(t *testing.T, c *cpu.CPU, bus *flatBus) {
+				assert.Equal(t, []byte{0xEF, 0xBE}, bus.memory[0xC000:0xC002])
+				assert.Equal(t, uint16(0x103), c.Registers.PC)
+			},
+		},
+	})
+}
+
+func TestResetBitHL(t *testing.T) {
+	runOpcodeCases(t, []opcodeCase{
+		{
+			name:    "RES 0,(HL)",
+			program: []byte{0xCB, 0x86},
+			setup: func(c *cpu.CPU, bus *flatBus) {
+				c.Registers.SetHL(0xC000)
+				bus.memory[0xC000] = 0xFF
+			},
+			check: func(t *testing.T, c *cpu.CPU, bus *flatBus) {
+				assert.Equal(t, byte(0xFE), bus.memory[0xC000])
+				assert.Equal(t, uint16(0xC000), c.Registers.GetHL())
+			},
+		},
+	})
+}
+
+func TestDerefC(t *testing.T) {
+	runOpcodeCases(t, []opcodeCase{
+		{
+			name:    "LD (C),A",
+			program: []byte{0xE2},
+			setup: func(c *cpu.CPU, bus *flatBus) {
+				c.Registers.A, c.Registers.C = 0x42, 0x80
+			},
+			check: func(t *testing.T, c *cpu.CPU, bus *flatBus) {
+				assert.Equal(t, byte(0x42), bus.memory[0xFF80])
+				assert.Equal(t, byte(0x00), bus.memory[0x0080])
+			},
+		},
+		{
+			name:    "LD A,(C)",
+			program: []byte{0xF2},
+			setup: func(c *cpu.CPU, bus *flatBus) {
+				c.Registers.C = 0x81
+				bus.memory[0xFF81] = 0x99
+			},
+			check: func(t *testing.T, c *cpu.CPU, bus *flatBus) {
+				assert.Equal(t, byte(0x99), c.Registers.A)
+			},
+		},
+	})
+}
+
+func TestCarryConditions(t *testing.T) {
+	withCarry := func(c *cpu.CPU, bus *flatBus) {
+		c.Registers.F = carry
+		// return address for RET C
+		c.Registers.SP = 0xFFFC
+		bus.memory[0xFFFC], bus.memory[0xFFFD] = 0x34, 0x12
+	}
+	withoutCarry := func(c *cpu.CPU, bus *flatBus) {
+		withCarry(c, bus)
+		c.Registers.F = 0
+	}
+	pc := func(want uint16) func(t *testing.T, c *cpu.CPU, bus *flatBus) {
+		return func(t *testing.T, c *cpu.CPU, bus *flatBus) {
+			assert.Equal(t, want, c.Registers.PC)
+		}
+	}
+
+	runOpcodeCases(t, []opcodeCase{
+		{"JR C,r8 taken", []byte{0x38, 0x05}, withCarry, pc(0x107)},
+		{"JR C,r8 not taken", []byte{0x38, 0x05}, withoutCarry, pc(0x102)},
+		{"RET C taken", []byte{0xD8}, withCarry, pc(0x1234)},
+		{"RET C not taken", []byte{0xD8}, withoutCarry, pc(0x101)},
+		{"CALL C,a16 taken", []byte{0xDC, 0x00, 0x20}, withCarry, func(t *testing.T, c *cpu.CPU, bus *flatBus) {
+			assert.Equal(t, uint16(0x2000), c.Registers.PC)
+			assert.Equal(t, uint16(0xFFFA), c.Registers.SP)
+			assert.Equal(t, []byte{0x03, 0x01}, bus.memory[0xFFFA:0xFFFC])
+		}},
+		{"CALL C,a16 not taken", []byte{0xDC, 0x00, 0x20}, withoutCarry, pc(0x103)},
+	})
+}
@@ -175,6 +175,13 @@ func LD(cpu *CPU, ops []Operand) {
 	src := &ops[1]
 	srcData := cpu.Get(src)
 
+	// special case for 0x08: LD (a16),SP is the only 16 bit store to memory
+	if src.Symbol == SP && dst.Deref {
+		addr := cpu.Get(dst)
+		cpu.Write16(addr, srcData)
+		return
+	}
+
 	if _, ok := src.Symbol.(Address); ok && src.Deref {
 		// if the source is an address and is deref, get the value
 		// this is for instruction 0xFA: LD A,(a16)
@@ -289,14 +296,14 @@ func SUB(cpu *CPU, ops []Operand) {
 // SBC: Subtract a value (with carry flag) from another value
 func SBC(cpu *CPU, ops []Operand) {
 	valA := uint16(cpu.Registers.A)
-	valB := cpu.Get(&ops[0])
+	valB := cpu.Get(&ops[len(ops)-1])
 
 	var carry uint16
 	if cpu.Registers.GetFlag(FlagC) {
 		carry = 1
 	}
 
-	diff := valA - valB + carry
+	diff := valA - valB - carry
 
 	cpu.Registers.Set(A, diff)
 	cpu.Registers.SetFlag(FlagZ, (diff&0xFF) == 0)
@@ -504,14 +511,7 @@ func RES(cpu *CPU, ops []Operand) {
 
 	result := bits.ClearNBit(byte(val), byte(bit))
 
-	// set address if deref
-	if ops[1].Deref {
-		cpu.Write8(val, byte(result))
-	} else { // otherwise set register
-		reg := ops[1].Symbol.(Register)
-		cpu.Registers.Set(reg, uint16(result))
-	}
-
+	cpu.Set(&ops[1], uint16(result))
 	// no flags affected
 }
 
@@ -635,55 +635,65 @@ func SWAP(cpu *CPU, ops []Operand) {
 
 // ILLEGAL_D3: illegal D3 instruction
 func ILLEGAL_D3(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xD3))
+	cpu.illegal(0xD3)
 }
 
 // ILLEGAL_DB: illegal DB instruction
 func ILLEGAL_DB(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xDB))
+	cpu.illegal(0xDB)
 }
 
 // ILLEGAL_DD: illegal DD instruction
 func ILLEGAL_DD(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xDD))
+	cpu.illegal(0xDD)
 }
 
 // ILLEGAL_E3: illegal E3 instruction
 func ILLEGAL_E3(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xE3))
+	cpu.illegal(0xE3)
 }
 
 // ILLEGAL_E4: illegal E4 instruction
 func ILLEGAL_E4(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xE4))
+	cpu.illegal(0xE4)
 }
 
 // ILLEGAL_EB: illegal EB instruction
 func ILLEGAL_EB(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xEB))
+	cpu.illegal(0xEB)
 }
 
 // ILLEGAL_EC: illegal EC instruction
 func ILLEGAL_EC(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xEC))
+	cpu.illegal(0xEC)
 }
 
 // ILLEGAL_ED: illegal ED instruction
 func ILLEGAL_ED(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xED))
+	cpu.illegal(0xED)
 }
 
 // ILLEGAL_F4: illegal F4 instruction
 func ILLEGAL_F4(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xF4))
+	cpu.illegal(0xF4)
 }
 
 // ILLEGAL_FC: illegal FC instruction
 func ILLEGAL_FC(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xFC))
+	cpu.illegal(0xFC)
 }
 
 // ILLEGAL_FD: illegal FD instruction
 func ILLEGAL_FD(cpu *CPU, ops []Operand) {
-	panic(errs.NewIllegalInstructionError(0xFD))
+	cpu.illegal(0xFD)
+}
+
+// illegal opcodes lock up the cpu until it's reset, in strict mode they panic instead
+// https://gbdev.io/pandocs/CPU_Instruction_Set.html
+func (cpu *CPU) illegal(opcode byte) {
+	if cpu.Strict {
+		panic(errs.NewIllegalInstructionError(opcode))
+	}
+
+	cpu.Locked = true
 }
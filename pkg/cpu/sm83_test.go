@@ -0,0 +1,240 @@
+package cpu_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/cpu"
+)
+
+// Runs the community single step tests for the SM83, one json file per opcode:
+// https://github.com/SingleStepTests/sm83
+// The vectors are too large to check in, drop the v1 directory into testdata/sm83 to run them.
+
+var (
+	sm83Dir    = flag.String("sm83.dir", filepath.Join("testdata", "sm83"), "directory of sm83 single step test vectors")
+	sm83Cycles = flag.Bool("sm83.cycles", false, "also compare the number of cycles and per-cycle bus activity")
+)
+
+// maximum number of failing cases reported per opcode
+const sm83MaxReports = 5
+
+type sm83State struct {
+	PC  uint16      `json:"pc"`
+	SP  uint16      `json:"sp"`
+	A   byte        `json:"a"`
+	B   byte        `json:"b"`
+	C   byte        `json:"c"`
+	D   byte        `json:"d"`
+	E   byte        `json:"e"`
+	F   byte        `json:"f"`
+	H   byte        `json:"h"`
+	L   byte        `json:"l"`
+	IME *byte       `json:"ime"`
+	IE  *byte       `json:"ie"`
+	RAM [][2]uint16 `json:"ram"`
+}
+
+type sm83Case struct {
+	Name    string          `json:"name"`
+	Initial sm83State       `json:"initial"`
+	Final   sm83State       `json:"final"`
+	Cycles  [][]interface{} `json:"cycles"`
+}
+
+// access is a single read or write seen on the bus
+type access struct {
+	address uint16
+	value   byte
+	write   bool
+}
+
+func (a access) String() string {
+	kind := "read"
+	if a.write {
+		kind = "write"
+	}
+	return fmt.Sprintf("%s %04X=%02X", kind, a.address, a.value)
+}
+
+// flatBus is 64KiB of ram with no memory mapped hardware, recording every access
+type flatBus struct {
+	memory   [0x10000]byte
+	accesses []access
+}
+
+func (b *flatBus) Read8(address uint16) byte {
+	val := b.memory[address]
+	b.accesses = append(b.accesses, access{address, val, false})
+	return val
+}
+
+func (b *flatBus) Write8(address uint16, data byte) {
+	b.memory[address] = data
+	b.accesses = append(b.accesses, access{address, data, true})
+}
+
+func (b *flatBus) Tick() {}
+
+func TestSM83(t *testing.T) {
+	files, _ := filepath.Glob(filepath.Join(*sm83Dir, "*.json"))
+	if len(files) == 0 {
+		t.Skipf("no sm83 test vectors in %s", *sm83Dir)
+	}
+
+	for _, file := range files {
+		opcode := strings.TrimSuffix(filepath.Base(file), ".json")
+		t.Run(opcode, func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cases := []sm83Case{}
+			if err := json.Unmarshal(data, &cases); err != nil {
+				t.Fatal(err)
+			}
+
+			failures := 0
+			for _, tc := range cases {
+				mismatches := runSM83Case(tc)
+				if len(mismatches) == 0 {
+					continue
+				}
+
+				failures++
+				if failures <= sm83MaxReports {
+					t.Errorf("%s:\n\t%s", tc.Name, strings.Join(mismatches, "\n\t"))
+				}
+			}
+
+			if failures > 0 {
+				t.Errorf("%d/%d cases failed", failures, len(cases))
+			}
+		})
+	}
+}
+
+// runSM83Case executes a single instruction from the initial state, returning how the result differs from the final state
+func runSM83Case(tc sm83Case) (mismatches []string) {
+	bus := &flatBus{}
+	c := cpu.New(nil, cpu.WithBus(bus), cpu.WithRegisters(&cpu.Registers{}))
+
+	in := tc.Initial
+	c.Registers.A, c.Registers.F = in.A, in.F
+	c.Registers.B, c.Registers.C = in.B, in.C
+	c.Registers.D, c.Registers.E = in.D, in.E
+	c.Registers.H, c.Registers.L = in.H, in.L
+	c.Registers.SP, c.Registers.PC = in.SP, in.PC
+	if in.IME != nil {
+		c.Interrupt.MasterEnabled = *in.IME != 0
+	}
+	if in.IE != nil {
+		c.Interrupt.Enable = *in.IE
+	}
+	for _, entry := range in.RAM {
+		bus.memory[entry[0]] = byte(entry[1])
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			mismatches = append(mismatches, fmt.Sprintf("panic: %v", r))
+		}
+	}()
+
+	_, instruction := c.NextInstruction()
+	instruction.Execute(c)
+
+	want := tc.Final
+	regs := []struct {
+		name      string
+		got, want uint16
+	}{
+		{"A", uint16(c.Registers.A), uint16(want.A)},
+		{"B", uint16(c.Registers.B), uint16(want.B)},
+		{"C", uint16(c.Registers.C), uint16(want.C)},
+		{"D", uint16(c.Registers.D), uint16(want.D)},
+		{"E", uint16(c.Registers.E), uint16(want.E)},
+		{"H", uint16(c.Registers.H), uint16(want.H)},
+		{"L", uint16(c.Registers.L), uint16(want.L)},
+		{"SP", c.Registers.SP, want.SP},
+		{"PC", c.Registers.PC, want.PC},
+	}
+	for _, reg := range regs {
+		if reg.got != reg.want {
+			mismatches = append(mismatches, fmt.Sprintf("%s: got 0x%X, want 0x%X", reg.name, reg.got, reg.want))
+		}
+	}
+
+	if c.Registers.F != want.F {
+		mismatches = append(mismatches, fmt.Sprintf("F: got %s, want %s", flagString(c.Registers.F), flagString(want.F)))
+	}
+
+	if want.IME != nil && c.Interrupt.MasterEnabled != (*want.IME != 0) {
+		mismatches = append(mismatches, fmt.Sprintf("IME: got %t, want %t", c.Interrupt.MasterEnabled, *want.IME != 0))
+	}
+
+	for _, entry := range want.RAM {
+		if got := bus.memory[entry[0]]; got != byte(entry[1]) {
+			mismatches = append(mismatches, fmt.Sprintf("RAM[%04X]: got 0x%02X, want 0x%02X", entry[0], got, entry[1]))
+		}
+	}
+
+	if *sm83Cycles {
+		mismatches = append(mismatches, compareCycles(c, bus, tc.Cycles)...)
+	}
+
+	return mismatches
+}
+
+// compareCycles checks the M-cycle count and the reads/writes seen on the bus.
+// Each cycle is [address, value, activity], where activity is like "r-m" or "-wm" (or null when idle)
+func compareCycles(c *cpu.CPU, bus *flatBus, cycles [][]interface{}) (mismatches []string) {
+	if got := c.Ticks / 4; got != uint64(len(cycles)) {
+		mismatches = append(mismatches, fmt.Sprintf("cycles: got %d, want %d", got, len(cycles)))
+	}
+
+	expected := []access{}
+	for _, cycle := range cycles {
+		if len(cycle) < 3 {
+			continue
+		}
+
+		addr, okAddr := cycle[0].(float64)
+		val, okVal := cycle[1].(float64)
+		activity, _ := cycle[2].(string)
+		if !okAddr || !okVal {
+			continue
+		}
+
+		switch {
+		case strings.Contains(activity, "r"):
+			expected = append(expected, access{uint16(addr), byte(val), false})
+		case strings.Contains(activity, "w"):
+			expected = append(expected, access{uint16(addr), byte(val), true})
+		}
+	}
+
+	if fmt.Sprint(bus.accesses) != fmt.Sprint(expected) {
+		mismatches = append(mismatches, fmt.Sprintf("bus: got %v, want %v", bus.accesses, expected))
+	}
+
+	return mismatches
+}
+
+// flagString formats the F register like "Z-HC"
+func flagString(f byte) string {
+	str := []byte("ZNHC")
+	for i := range str {
+		if f&(0x80>>i) == 0 {
+			str[i] = '-'
+		}
+	}
+
+	return fmt.Sprintf("%s (0x%02X)", str, f)
+}
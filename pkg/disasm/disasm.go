@@ -0,0 +1,336 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robherley/go-gameboy/internal/bits"
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/robherley/go-gameboy/pkg/cpu"
+)
+
+// https://rgbds.gbdev.io/docs/gbz80.7
+
+// Flow describes how an instruction affects the program counter
+type Flow byte
+
+const (
+	// FlowNone: execution continues with the next instruction
+	FlowNone Flow = iota
+	// FlowJump: unconditional jump (JP, JR), execution does not continue
+	FlowJump
+	// FlowBranch: conditional jump, execution may continue or jump to target
+	FlowBranch
+	// FlowCall: subroutine call (CALL, RST), execution continues after return
+	FlowCall
+	// FlowReturn: unconditional return or computed jump (RET, RETI, JP HL)
+	FlowReturn
+)
+
+// Line is a single decoded instruction, or a run of data bytes
+type Line struct {
+	// Bank is the ROM bank the line was decoded from
+	Bank int
+	// Address is the CPU address of the first byte
+	Address uint16
+	// Bytes are the raw bytes that make up the line
+	Bytes []byte
+	// Mnemonic in RGBDS syntax, ie: ld, jp, db
+	Mnemonic string
+	// Operands in RGBDS syntax with immediates resolved, ie: [hl+], $0150
+	Operands []string
+	// Flow indicates how the instruction changes control flow
+	Flow Flow
+	// Target is the resolved destination address when HasTarget is set
+	Target    uint16
+	HasTarget bool
+}
+
+// IsData checks if the line is a data directive rather than an instruction
+func (l *Line) IsData() bool {
+	return l.Mnemonic == "db"
+}
+
+// Instruction returns the mnemonic and operands, ie: "ld a, [hl+]"
+func (l *Line) Instruction() string {
+	if len(l.Operands) == 0 {
+		return l.Mnemonic
+	}
+
+	return l.Mnemonic + " " + strings.Join(l.Operands, ", ")
+}
+
+// String formats the line as: bank:address  raw bytes  instruction
+func (l *Line) String() string {
+	raw := make([]string, len(l.Bytes))
+	for i, b := range l.Bytes {
+		raw[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return fmt.Sprintf("%02X:%04X  %-9s  %s", l.Bank, l.Address, strings.Join(raw, " "), l.Instruction())
+}
+
+// BankWindow returns the CPU address range (end exclusive) a ROM bank is mapped to
+func BankWindow(bank int) (uint16, uint16) {
+	if bank == 0 {
+		return 0x0000, 0x4000
+	}
+
+	return 0x4000, 0x8000
+}
+
+// BankFor returns which bank is read at address, given the bank switched into 0x4000-0x7FFF
+func BankFor(address uint16, switched int) int {
+	if address < cartridge.ROMBankSize {
+		return 0
+	}
+
+	return switched
+}
+
+// Decode disassembles the instruction at address, reading from bank.
+// Illegal opcodes and instructions truncated by the end of the bank are decoded as data.
+func Decode(cart *cartridge.Cartridge, bank int, address uint16) Line {
+	_, end := BankWindow(bank)
+	read := func(offset int) (byte, bool) {
+		addr := int(address) + offset
+		if addr >= int(end) || bank*cartridge.ROMBankSize+addr%cartridge.ROMBankSize >= cart.Size {
+			return 0, false
+		}
+		return cart.ReadBank(bank, uint16(addr)), true
+	}
+
+	opcode, ok := read(0)
+	if !ok {
+		return Line{Bank: bank, Address: address, Mnemonic: "db"}
+	}
+
+	data := func() Line {
+		return Line{
+			Bank:     bank,
+			Address:  address,
+			Bytes:    []byte{opcode},
+			Mnemonic: "db",
+			Operands: []string{fmt.Sprintf("$%02X", opcode)},
+		}
+	}
+
+	raw := []byte{opcode}
+	isCB := opcode == 0xCB
+	if isCB {
+		next, ok := read(1)
+		if !ok {
+			return data()
+		}
+		opcode = next
+		raw = append(raw, next)
+	}
+
+	in := cpu.InstructionFromOPCode(opcode, isCB)
+	if in == nil {
+		return data()
+	}
+
+	mnemonic := in.Mnemonic()
+	if strings.HasPrefix(mnemonic, "ILLEGAL") {
+		return data()
+	}
+
+	// gather immediate bytes for the instruction
+	for _, op := range in.Operands {
+		for i := 0; i < immediateSize(op); i++ {
+			b, ok := read(len(raw))
+			if !ok {
+				return data()
+			}
+			raw = append(raw, b)
+		}
+	}
+
+	line := Line{
+		Bank:     bank,
+		Address:  address,
+		Bytes:    raw,
+		Mnemonic: strings.ToLower(mnemonic),
+	}
+
+	// immediate values start after the opcode(s)
+	imm := raw[len(raw)-immediateTotal(in.Operands):]
+	next := address + uint16(len(raw))
+
+	for _, op := range in.Operands {
+		size := immediateSize(op)
+		line.Operands = append(line.Operands, formatOperand(in, op, imm[:size], next, &line))
+		imm = imm[size:]
+	}
+
+	switch in.Mnemonic() {
+	case "STOP":
+		// STOP is encoded as 10 00, the second byte is not an operand
+		line.Operands = nil
+	case "LD":
+		// LD (C),A and LD A,(C) are spelled ldh [c] in RGBDS
+		for _, op := range in.Operands {
+			if op.Symbol == cpu.C && op.Deref {
+				line.Mnemonic = "ldh"
+			}
+		}
+
+		// LD HL,SP+r8 is a single operand in RGBDS
+		if len(in.Operands) == 3 {
+			line.Operands = []string{line.Operands[0], line.Operands[1] + line.Operands[2]}
+		}
+	}
+
+	line.Flow = flowFor(in)
+
+	return line
+}
+
+// Range linearly disassembles bank from address "from" up to (but not including) "to".
+// The range is clamped to the window the bank is mapped to.
+func Range(cart *cartridge.Cartridge, bank int, from, to uint16) []Line {
+	start, end := BankWindow(bank)
+	if from < start {
+		from = start
+	}
+	if to > end {
+		to = end
+	}
+
+	lines := []Line{}
+	for addr := int(from); addr < int(to); {
+		line := Decode(cart, bank, uint16(addr))
+		if len(line.Bytes) == 0 {
+			// past the end of the rom
+			break
+		}
+		lines = append(lines, line)
+		addr += len(line.Bytes)
+	}
+
+	return lines
+}
+
+// immediateSize returns how many bytes of immediate data the operand consumes
+func immediateSize(op cpu.Operand) int {
+	switch op.Symbol {
+	case cpu.D8, cpu.R8, cpu.A8:
+		return 1
+	case cpu.D16, cpu.A16:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func immediateTotal(ops []cpu.Operand) int {
+	total := 0
+	for _, op := range ops {
+		total += immediateSize(op)
+	}
+	return total
+}
+
+func formatOperand(in *cpu.Instruction, op cpu.Operand, imm []byte, next uint16, line *Line) string {
+	var str string
+
+	switch symbol := op.Symbol.(type) {
+	case cpu.Register:
+		str = strings.ToLower(string(symbol))
+		if op.Inc && op.Deref {
+			str += "+"
+		}
+		if op.Dec && op.Deref {
+			str += "-"
+		}
+	case cpu.Condition:
+		str = strings.ToLower(string(symbol))
+	case cpu.Byte:
+		if in.Mnemonic() == "RST" {
+			str = fmt.Sprintf("$%02X", byte(symbol))
+			line.Target, line.HasTarget = uint16(symbol), true
+		} else {
+			str = fmt.Sprintf("%d", byte(symbol))
+		}
+	case cpu.Data:
+		switch symbol {
+		case cpu.D8:
+			str = fmt.Sprintf("$%02X", imm[0])
+		case cpu.D16:
+			str = fmt.Sprintf("$%04X", bits.To16(imm[1], imm[0]))
+		case cpu.R8:
+			offset := int8(imm[0])
+			if in.Mnemonic() == "JR" {
+				line.Target, line.HasTarget = next+uint16(offset), true
+				str = fmt.Sprintf("$%04X", line.Target)
+			} else if in.Mnemonic() == "LD" {
+				// LD HL,SP+r8
+				str = signed(offset)
+			} else {
+				// ADD SP,r8
+				str = signed(offset)
+				if offset >= 0 {
+					str = str[1:]
+				}
+			}
+		}
+	case cpu.Address:
+		switch symbol {
+		case cpu.A8:
+			str = fmt.Sprintf("$FF%02X", imm[0])
+		case cpu.A16:
+			addr := bits.To16(imm[1], imm[0])
+			str = fmt.Sprintf("$%04X", addr)
+			if !op.Deref {
+				line.Target, line.HasTarget = addr, true
+			}
+		}
+	}
+
+	if op.Deref {
+		str = "[" + str + "]"
+	}
+
+	return str
+}
+
+// signed formats a signed offset with an explicit sign, ie: +$05, -$03
+func signed(offset int8) string {
+	if offset < 0 {
+		return fmt.Sprintf("-$%02X", -int(offset))
+	}
+
+	return fmt.Sprintf("+$%02X", offset)
+}
+
+func flowFor(in *cpu.Instruction) Flow {
+	conditional := false
+	if len(in.Operands) > 0 {
+		_, conditional = in.Operands[0].Symbol.(cpu.Condition)
+	}
+
+	switch in.Mnemonic() {
+	case "JP":
+		if in.Operands[0].Symbol == cpu.HL {
+			return FlowReturn
+		}
+		fallthrough
+	case "JR":
+		if conditional {
+			return FlowBranch
+		}
+		return FlowJump
+	case "CALL", "RST":
+		return FlowCall
+	case "RET":
+		if conditional {
+			return FlowBranch
+		}
+		return FlowReturn
+	case "RETI":
+		return FlowReturn
+	default:
+		return FlowNone
+	}
+}
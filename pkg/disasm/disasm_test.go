@@ -0,0 +1,78 @@
+package disasm_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/robherley/go-gameboy/pkg/disasm"
+	"github.com/stretchr/testify/assert"
+)
+
+func romWith(address uint16, code ...byte) *cartridge.Cartridge {
+	data := make([]byte, 2*cartridge.ROMBankSize)
+	copy(data[address:], code)
+
+	cart, _ := cartridge.FromBytes(data)
+	return cart
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		code     []byte
+		expected string
+		size     int
+	}{
+		{[]byte{0x00}, "nop", 1},
+		{[]byte{0x31, 0xFE, 0xFF}, "ld sp, $FFFE", 3},
+		{[]byte{0x22}, "ld [hl+], a", 1},
+		{[]byte{0x3A}, "ld a, [hl-]", 1},
+		{[]byte{0xE0, 0x40}, "ldh [$FF40], a", 2},
+		{[]byte{0xF2}, "ldh a, [c]", 1},
+		{[]byte{0xFA, 0x34, 0x12}, "ld a, [$1234]", 3},
+		{[]byte{0x08, 0x00, 0xC0}, "ld [$C000], sp", 3},
+		{[]byte{0xF8, 0xFE}, "ld hl, sp-$02", 2},
+		{[]byte{0xE8, 0x05}, "add sp, $05", 2},
+		{[]byte{0xC2, 0x50, 0x01}, "jp nz, $0150", 3},
+		{[]byte{0x20, 0xFE}, "jr nz, $0150", 2},
+		{[]byte{0xCD, 0x00, 0x40}, "call $4000", 3},
+		{[]byte{0xFF}, "rst $38", 1},
+		{[]byte{0xCB, 0x7E}, "bit 7, [hl]", 2},
+		{[]byte{0x10, 0x00}, "stop", 2},
+		{[]byte{0xD3}, "db $D3", 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("Decode(% X)", tc.code), func(t *testing.T) {
+			line := disasm.Decode(romWith(0x150, tc.code...), 0, 0x150)
+			assert.Equal(t, tc.expected, line.Instruction())
+			assert.Len(t, line.Bytes, tc.size)
+		})
+	}
+}
+
+func TestTrace(t *testing.T) {
+	cart := romWith(0x100,
+		0xC3, 0x50, 0x01, // jp $0150
+		0xDE, 0xAD, // data
+	)
+	copy(cart.Data[0x150:], []byte{
+		0xCD, 0x00, 0x40, // call $4000
+		0x18, 0xFE, // jr $0153
+	})
+	copy(cart.Data[0x4000:], []byte{
+		0xC9, // ret
+	})
+
+	code := disasm.Trace(cart)
+
+	assert.Contains(t, code, disasm.Location{Bank: 0, Address: 0x100})
+	assert.Contains(t, code, disasm.Location{Bank: 0, Address: 0x150})
+	assert.Contains(t, code, disasm.Location{Bank: 0, Address: 0x153})
+	assert.Contains(t, code, disasm.Location{Bank: 1, Address: 0x4000})
+	assert.NotContains(t, code, disasm.Location{Bank: 0, Address: 0x103})
+
+	lines := disasm.Listing(cart, 0, 0x100, 0x105, code)
+	assert.Equal(t, "jp $0150", lines[0].Instruction())
+	assert.Equal(t, "db $DE, $AD", lines[1].Instruction())
+}
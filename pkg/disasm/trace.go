@@ -0,0 +1,115 @@
+package disasm
+
+import (
+	"fmt"
+
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+)
+
+// maximum number of bytes grouped into a single db line
+const dataLineSize = 8
+
+// Vectors are where execution starts without being jumped to: the interrupt handlers and the entry point
+var Vectors = [...]uint16{0x40, 0x48, 0x50, 0x58, 0x60, 0x100}
+
+// Location is a banked ROM address
+type Location struct {
+	Bank    int
+	Address uint16
+}
+
+// Trace does a recursive descent from the vectors, following JP/CALL/JR/RST targets
+// to separate code from data. The MBC state isn't known statically, so jumps from
+// bank 0 into 0x4000-0x7FFF are assumed to land in bank 1, and jumps from a switchable
+// bank stay within that bank. Computed jumps (JP HL) can't be followed.
+func Trace(cart *cartridge.Cartridge) map[Location]Line {
+	code := map[Location]Line{}
+
+	queue := []Location{}
+	for _, vector := range Vectors {
+		queue = append(queue, Location{0, vector})
+	}
+
+	for len(queue) > 0 {
+		loc := queue[0]
+		queue = queue[1:]
+
+		for {
+			if _, seen := code[loc]; seen || !inROM(cart, loc) {
+				break
+			}
+
+			line := Decode(cart, loc.Bank, loc.Address)
+			if len(line.Bytes) == 0 || line.IsData() {
+				break
+			}
+			code[loc] = line
+
+			if line.HasTarget {
+				switched := loc.Bank
+				if switched == 0 {
+					switched = 1
+				}
+				queue = append(queue, Location{BankFor(line.Target, switched), line.Target})
+			}
+
+			if line.Flow == FlowJump || line.Flow == FlowReturn {
+				break
+			}
+
+			loc.Address += uint16(len(line.Bytes))
+		}
+	}
+
+	return code
+}
+
+// Listing disassembles bank from address "from" up to (but not including) "to", using
+// the traced code and emitting every other byte as data
+func Listing(cart *cartridge.Cartridge, bank int, from, to uint16, code map[Location]Line) []Line {
+	start, end := BankWindow(bank)
+	if from < start {
+		from = start
+	}
+	if to > end {
+		to = end
+	}
+
+	lines := []Line{}
+	// index of the db line being filled, if any
+	data := -1
+
+	for addr := int(from); addr < int(to); {
+		loc := Location{bank, uint16(addr)}
+		if !inROM(cart, loc) {
+			break
+		}
+
+		if line, ok := code[loc]; ok {
+			data = -1
+			lines = append(lines, line)
+			addr += len(line.Bytes)
+			continue
+		}
+
+		b := cart.ReadBank(bank, loc.Address)
+		if data == -1 || len(lines[data].Bytes) == dataLineSize {
+			lines = append(lines, Line{Bank: bank, Address: loc.Address, Mnemonic: "db"})
+			data = len(lines) - 1
+		}
+		lines[data].Bytes = append(lines[data].Bytes, b)
+		lines[data].Operands = append(lines[data].Operands, fmt.Sprintf("$%02X", b))
+		addr++
+	}
+
+	return lines
+}
+
+func inROM(cart *cartridge.Cartridge, loc Location) bool {
+	start, end := BankWindow(loc.Bank)
+	if loc.Address < start || loc.Address >= end {
+		return false
+	}
+
+	return loc.Bank < cart.ROMBanks() && loc.Bank*cartridge.ROMBankSize+int(loc.Address%cartridge.ROMBankSize) < cart.Size
+}
@@ -0,0 +1,347 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/robherley/go-gameboy/pkg/apu"
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	errs "github.com/robherley/go-gameboy/pkg/errors"
+	"github.com/robherley/go-gameboy/pkg/interrupt"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/mmu"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+	"github.com/robherley/go-gameboy/pkg/timer"
+)
+
+// Best Effort Save State, a format for exchanging save states with other emulators like SameBoy.
+// A BESS file is a dump of the memory regions, followed by blocks that each have a 4 character id
+// and a little endian uint32 length, then a footer with the offset of the first block and "BESS".
+// https://github.com/LIJI32/SameBoy/blob/master/BESS.md
+
+const (
+	bessMagic = "BESS"
+	// version of the CORE block
+	bessMajor = 1
+	bessMinor = 1
+	// DMG-B
+	bessModel = "GDB "
+
+	// emulator name in the NAME block
+	bessName = "go-gameboy"
+)
+
+// execution states in the CORE block
+const (
+	bessRunning byte = iota
+	bessHalted
+	bessStopped
+)
+
+// bessRegion is where a memory region was dumped
+type bessRegion struct {
+	Size   uint32
+	Offset uint32
+}
+
+type bessCore struct {
+	Major, Minor   uint16
+	Model          [4]byte
+	PC, AF, BC, DE uint16
+	HL, SP         uint16
+	IME, IE        byte
+	ExecutionState byte
+	_              byte
+	// 0xFF00-0xFF7F
+	IO [0x80]byte
+
+	RAM, VRAM, MBCRAM, OAM, HRAM       bessRegion
+	BackgroundPalettes, ObjectPalettes bessRegion
+}
+
+// IsBESS checks if data ends with a BESS footer
+func IsBESS(data []byte) bool {
+	return len(data) >= 8 && string(data[len(data)-4:]) == bessMagic
+}
+
+// ExportBESS writes a snapshot of the machine in the BESS format. The format doesn't have room for
+// everything (ie: the EI delay, timer internals or a locked up cpu), so prefer SaveState when the
+// state doesn't need to leave go-gameboy
+func (emu *Emulator) ExportBESS(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	regs := emu.CPU.Registers
+
+	core := bessCore{
+		Major: bessMajor,
+		Minor: bessMinor,
+		PC:    regs.PC,
+		AF:    regs.GetAF(),
+		BC:    regs.GetBC(),
+		DE:    regs.GetDE(),
+		HL:    regs.GetHL(),
+		SP:    regs.SP,
+		IE:    emu.CPU.Interrupt.Enable,
+	}
+	copy(core.Model[:], bessModel)
+
+	if emu.CPU.Interrupt.MasterEnabled {
+		core.IME = 1
+	}
+	if emu.CPU.Halted {
+		core.ExecutionState = bessHalted
+	}
+
+	for i := range core.IO {
+		core.IO[i] = emu.peek(0xFF00 + uint16(i))
+	}
+
+	// memory dumps come first, the CORE block points at them
+	dump := func(start uint16, size int) bessRegion {
+		region := bessRegion{Size: uint32(size), Offset: uint32(buf.Len())}
+		for i := 0; i < size; i++ {
+			buf.WriteByte(emu.peek(start + uint16(i)))
+		}
+		return region
+	}
+
+	core.RAM = dump(mmu.WRAM_OFFSET, mmu.WRAM_SIZE)
+	core.VRAM = dump(ppu.VRAM_OFFSET, ppu.VRAM_SIZE)
+	core.MBCRAM = bessRegion{Size: uint32(len(emu.Cartridge.RAM)), Offset: uint32(buf.Len())}
+	buf.Write(emu.Cartridge.RAM)
+	core.OAM = dump(ppu.OAM_OFFSET, ppu.OAM_SIZE)
+	// 0xFFFF is IE, which is in the CORE block
+	core.HRAM = dump(mmu.HRAM_OFFSET, mmu.HRAM_SIZE-1)
+
+	first := buf.Len()
+
+	block := func(id string, data []byte) {
+		buf.WriteString(id)
+		binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+		buf.Write(data)
+	}
+
+	block("NAME", []byte(bessName))
+
+	info := append([]byte{}, emu.Cartridge.Data[0x134:0x144]...)
+	block("INFO", append(info, emu.Cartridge.Data[0x14E:0x150]...))
+
+	data, _ := fixed{core}.MarshalBinary()
+	block("CORE", data)
+
+	if writes := emu.Cartridge.MBCRegisters(); len(writes) > 0 {
+		mbc := &bytes.Buffer{}
+		for _, write := range writes {
+			binary.Write(mbc, binary.LittleEndian, write)
+		}
+		block("MBC ", mbc.Bytes())
+	}
+
+	if rtc := emu.Cartridge.RTC(time.Now()); rtc != nil {
+		block("RTC ", rtc)
+	}
+
+	block("END ", nil)
+
+	binary.Write(buf, binary.LittleEndian, uint32(first))
+	buf.WriteString(bessMagic)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ImportBESS restores a BESS snapshot from another emulator, as best it can. The registers are
+// restored, but not what's behind them: the PPU starts from the beginning of the line and sound
+// channels stay silent until they're next triggered. The RTC block restores an MBC3 clock and
+// catches it up with the time since the state was saved, it's refused for cartridges without a
+// clock. Unknown blocks are skipped
+func (emu *Emulator) ImportBESS(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	blocks, err := readBESSBlocks(data)
+	if err != nil {
+		return err
+	}
+
+	if info, ok := blocks["INFO"]; ok {
+		cart := emu.Cartridge.Data
+		if len(info) != 0x12 || !bytes.Equal(info[:0x10], cart[0x134:0x144]) || !bytes.Equal(info[0x10:], cart[0x14E:0x150]) {
+			return fmt.Errorf("%w: state is for a different rom", errs.ErrorInvalidState)
+		}
+	}
+
+	core := bessCore{}
+	if len(blocks["CORE"]) < binary.Size(core) {
+		return fmt.Errorf("%w: missing or short CORE block", errs.ErrorInvalidState)
+	}
+	binary.Read(bytes.NewReader(blocks["CORE"]), binary.LittleEndian, &core)
+
+	if core.Major != bessMajor {
+		return fmt.Errorf("%w: unsupported BESS version %d.%d", errs.ErrorInvalidState, core.Major, core.Minor)
+	}
+
+	// a clock for a cartridge without one means the state is for a different rom
+	rtc, hasRTC := blocks["RTC "]
+	if hasRTC && !emu.Cartridge.HasClock() {
+		return fmt.Errorf("%w: RTC block for a cartridge without a clock", errs.ErrorInvalidState)
+	}
+	if hasRTC && len(rtc) != cartridge.RTCSize {
+		return fmt.Errorf("%w: RTC block is %d bytes, want %d", errs.ErrorInvalidState, len(rtc), cartridge.RTCSize)
+	}
+
+	if len(blocks["MBC "])%3 != 0 {
+		return fmt.Errorf("%w: invalid MBC block", errs.ErrorInvalidState)
+	}
+
+	regions := []struct {
+		region bessRegion
+		start  uint16
+		size   int
+	}{
+		{core.RAM, mmu.WRAM_OFFSET, mmu.WRAM_SIZE},
+		{core.VRAM, ppu.VRAM_OFFSET, ppu.VRAM_SIZE},
+		{core.OAM, ppu.OAM_OFFSET, ppu.OAM_SIZE},
+		{core.HRAM, mmu.HRAM_OFFSET, mmu.HRAM_SIZE - 1},
+		{core.MBCRAM, 0, len(emu.Cartridge.RAM)},
+	}
+
+	for _, r := range regions {
+		if uint64(r.region.Offset)+uint64(r.region.Size) > uint64(len(data)) {
+			return fmt.Errorf("%w: memory dump is out of bounds", errs.ErrorInvalidState)
+		}
+	}
+
+	// validated, now it can be applied
+	// mbc writes go first, so ram is banked the same way
+	mbc := blocks["MBC "]
+	for i := 0; i < len(mbc); i += 3 {
+		emu.Cartridge.Write(binary.LittleEndian.Uint16(mbc[i:]), mbc[i+2])
+	}
+
+	if hasRTC {
+		// the size was checked, it always loads
+		emu.Cartridge.SetRTC(rtc, time.Now())
+	}
+
+	for _, r := range regions {
+		// other models can have more (or less) memory, only what fits is restored
+		dump := data[r.region.Offset : r.region.Offset+r.region.Size]
+		if len(dump) > r.size {
+			dump = dump[:r.size]
+		}
+
+		if r.start == 0 {
+			copy(emu.Cartridge.RAM, dump)
+			continue
+		}
+
+		for i, b := range dump {
+			emu.MMU.Write8(r.start+uint16(i), b)
+		}
+	}
+
+	emu.importIO(core.IO)
+
+	regs := emu.CPU.Registers
+	regs.PC, regs.SP = core.PC, core.SP
+	regs.SetAF(core.AF)
+	regs.SetBC(core.BC)
+	regs.SetDE(core.DE)
+	regs.SetHL(core.HL)
+	emu.CPU.Interrupt.MasterEnabled = core.IME != 0
+	emu.CPU.Interrupt.Enable = core.IE
+	emu.CPU.Interrupt.EI = interrupt.MASTER_SET_NONE
+	emu.CPU.Interrupt.DI = interrupt.MASTER_SET_NONE
+	emu.CPU.Halted = core.ExecutionState != bessRunning
+	emu.CPU.Locked = false
+
+	emu.Pacer.Reset()
+
+	return nil
+}
+
+// importIO restores the registers at 0xFF00-0xFF7F that go-gameboy emulates. The timer is set
+// directly, writing DIV or TAC could increment TIMA
+func (emu *Emulator) importIO(registers [0x80]byte) {
+	reg := func(address uint16) byte {
+		return registers[address-0xFF00]
+	}
+
+	t := []byte{
+		0x00,
+		reg(timer.DIV_ADDRESS),
+		reg(timer.TIMA_ADDRESS),
+		reg(timer.TMA_ADDRESS),
+		reg(timer.TAC_ADDRESS) & 0b111,
+		0x00,
+		0x00,
+	}
+	emu.timer.UnmarshalBinary(t)
+
+	emu.MMU.Write8(mmu.SB_SERIAL_TRANSFER, reg(mmu.SB_SERIAL_TRANSFER))
+	// without the transfer bit, otherwise it would start sending
+	emu.MMU.Write8(mmu.SC_SERIAL_CONTROL, reg(mmu.SC_SERIAL_CONTROL)&^0x80)
+
+	emu.MMU.Write8(joypad.P1_ADDRESS, reg(joypad.P1_ADDRESS))
+	emu.CPU.Interrupt.Flag = reg(0xFF0F)
+
+	// power the APU on (or off) first, the other registers can't be written while it's off. Channels
+	// aren't triggered, they're silent until the game next starts a sound
+	emu.MMU.Write8(apu.NR52_ADDRESS, reg(apu.NR52_ADDRESS))
+	for address := apu.NR10_ADDRESS; address < apu.NR52_ADDRESS; address++ {
+		data := reg(address)
+		switch address {
+		case apu.NR14_ADDRESS, apu.NR24_ADDRESS, apu.NR34_ADDRESS, apu.NR44_ADDRESS:
+			data &^= 0x80
+		}
+		emu.MMU.Write8(address, data)
+	}
+	for i := uint16(0); i < apu.WAVE_RAM_SIZE; i++ {
+		emu.MMU.Write8(apu.WAVE_RAM_OFFSET+i, reg(apu.WAVE_RAM_OFFSET+i))
+	}
+
+	// LY and the mode are set directly too, the ppu continues from the start of the line. Writing
+	// DMA would start a transfer over the OAM that was just restored
+	p := emu.PPU
+	p.LCDC, p.SCY, p.SCX, p.LY, p.LYC = reg(ppu.LCDC_ADDRESS), reg(ppu.SCY_ADDRESS), reg(ppu.SCX_ADDRESS), reg(ppu.LY_ADDRESS), reg(ppu.LYC_ADDRESS)
+	p.BGP, p.OBP0, p.OBP1 = reg(ppu.BGP_ADDRESS), reg(ppu.OBP0_ADDRESS), reg(ppu.OBP1_ADDRESS)
+	p.WY, p.WX = reg(ppu.WY_ADDRESS), reg(ppu.WX_ADDRESS)
+	p.STAT = reg(ppu.STAT_ADDRESS) & 0x7F
+}
+
+// readBESSBlocks finds the blocks from the footer, keyed by id
+func readBESSBlocks(data []byte) (map[string][]byte, error) {
+	if !IsBESS(data) {
+		return nil, fmt.Errorf("%w: missing BESS footer", errs.ErrorInvalidState)
+	}
+
+	offset := uint64(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	end := uint64(len(data) - 8)
+
+	blocks := map[string][]byte{}
+	for {
+		if offset+8 > end {
+			return nil, fmt.Errorf("%w: BESS blocks are truncated", errs.ErrorInvalidState)
+		}
+
+		id := string(data[offset : offset+4])
+		size := uint64(binary.LittleEndian.Uint32(data[offset+4:]))
+		offset += 8
+
+		if offset+size > end {
+			return nil, fmt.Errorf("%w: %q block is truncated", errs.ErrorInvalidState, id)
+		}
+
+		if id == "END " {
+			return blocks, nil
+		}
+
+		blocks[id] = data[offset : offset+size]
+		offset += size
+	}
+}
@@ -1,38 +1,322 @@
 package emulator
 
 import (
+	"context"
+
+	"github.com/robherley/go-gameboy/pkg/apu"
+	"github.com/robherley/go-gameboy/pkg/audio"
 	"github.com/robherley/go-gameboy/pkg/cartridge"
 	"github.com/robherley/go-gameboy/pkg/cpu"
+	errs "github.com/robherley/go-gameboy/pkg/errors"
+	"github.com/robherley/go-gameboy/pkg/interrupt"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/mmu"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+	"github.com/robherley/go-gameboy/pkg/timer"
 )
 
 type Emulator struct {
-	CPU *cpu.CPU
+	Cartridge *cartridge.Cartridge
+	CPU       *cpu.CPU
+	MMU       *mmu.MMU
+	Joypad    *joypad.Joypad
+	PPU       *ppu.PPU
+	APU       *apu.APU
+	Tracer    Tracer
+	// FrameHook is called around every frame
+	FrameHook FrameHook
+	Pacer     *Pacer
+	// OnFrame is called when a frame should be presented, it's not called for skipped frames
+	OnFrame func()
+	// T-cycle the current frame ends on
+	frameEnd uint64
+	// number of frames run
+	frames uint64
+	timer  *timer.Timer
+	rewind *rewind
+	// audio receives the APU's output after every frame
+	audio []*audioOutput
+	// channelAudio receives each channel's output separately, the same way as audio
+	channelAudio [4]*audioOutput
 }
 
 func New(cart *cartridge.Cartridge) *Emulator {
+	inter := interrupt.New()
+	time := timer.New(func() {
+		inter.Flag |= byte(interrupt.TIMER)
+	})
+	pad := joypad.New(func() {
+		inter.Flag |= byte(interrupt.JOYPAD)
+	})
+	video := ppu.New(func() {
+		inter.Flag |= byte(interrupt.VBLANK)
+	}, func() {
+		inter.Flag |= byte(interrupt.LCD_STAT)
+	})
+	sound := apu.New()
+	memory := mmu.New(cart, inter, time, pad, video, sound)
+
 	return &Emulator{
-		CPU: cpu.New(cart),
+		CPU: cpu.New(
+			cart,
+			cpu.WithBus(memory),
+			cpu.WithInterrupt(inter),
+		),
+		Cartridge: cart,
+		MMU:       memory,
+		Joypad:    pad,
+		PPU:       video,
+		APU:       sound,
+		Pacer:     NewPacer(),
+		timer:     time,
 	}
 }
 
-func (emu *Emulator) Boot() {
+// FrameHook runs between frames, when input can change deterministically. Movies use it to feed and record input
+type FrameHook interface {
+	BeforeFrame(emu *Emulator) error
+	AfterFrame(emu *Emulator) error
+}
+
+// Policy is how the emulator handles programs doing things they shouldn't
+type Policy int
+
+const (
+	// PolicyHardware behaves like the hardware: illegal opcodes lock up the cpu, echo ram mirrors
+	// work ram and the unusable region reads as 0x00
+	PolicyHardware Policy = iota
+	// PolicyStrict fails fast with an error instead, for debugging homebrew
+	PolicyStrict
+)
+
+// SetPolicy sets how illegal opcodes and reserved memory are handled, the default is PolicyHardware
+func (emu *Emulator) SetPolicy(p Policy) {
+	emu.CPU.Strict = p == PolicyStrict
+	emu.MMU.SetStrict(p == PolicyStrict)
+}
+
+// Trace calls t before every instruction. If fakeLY is set, the LY register always reads 0x90,
+// which is what gameboy-doctor's reference logs were captured with.
+func (emu *Emulator) Trace(t Tracer, fakeLY bool) {
+	emu.Tracer = t
+	emu.PPU.FakeLY = t != nil && fakeLY
+}
+
+// Run the emulator in real time at the pacer's speed, until ctx is done or the emulator faults
+func (emu *Emulator) Run(ctx context.Context) error {
+	emu.Pacer.Reset()
+
 	for {
-		emu.Step()
-		// TODO: clock
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if err := emu.RunFrame(); err != nil {
+			return err
+		}
+
+		if emu.Pacer.Wait() && emu.OnFrame != nil {
+			emu.OnFrame()
+		}
+	}
+}
+
+// RunFrames runs n frames as fast as possible, or until ctx is done
+func (emu *Emulator) RunFrames(ctx context.Context, n int) error {
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := emu.RunFrame(); err != nil {
+			return err
+		}
+
+		if emu.OnFrame != nil {
+			emu.OnFrame()
+		}
+	}
+
+	return nil
+}
+
+// RunCycles runs at least n T-cycles, finishing the last instruction, or until ctx is done. ctx is
+// checked once per frame's worth of cycles
+func (emu *Emulator) RunCycles(ctx context.Context, n uint64) error {
+	end := emu.CPU.Ticks + n
+	next := emu.CPU.Ticks
+	for emu.CPU.Ticks < end {
+		if emu.CPU.Ticks >= next {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			next = emu.CPU.Ticks + CyclesPerFrame
+		}
+
+		if err := emu.Step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// audioOutput is a sink with its own resampler
+type audioOutput struct {
+	sink      audio.SampleSink
+	resampler *audio.Resampler
+}
+
+func newAudioOutput(sink audio.SampleSink, rate int) *audioOutput {
+	return &audioOutput{sink: sink, resampler: audio.NewResampler(apu.SampleRate, rate)}
+}
+
+// write sends the samples from the last frame
+func (out *audioOutput) write() error {
+	if err := out.sink.WriteSamples(out.resampler.Flush()); err != nil {
+		return err
+	}
+
+	if rc, ok := out.sink.(audio.RateController); ok {
+		out.resampler.SetRateAdjust(rc.RateAdjust())
+	}
+	return nil
+}
+
+// AddAudio sends the sound to the sink after every frame, resampled to the rate. If the sink is an
+// audio.RateController the rate is adjusted as it asks. The returned func removes the sink
+func (emu *Emulator) AddAudio(sink audio.SampleSink, rate int) (remove func()) {
+	out := newAudioOutput(sink, rate)
+	emu.audio = append(emu.audio, out)
+	emu.APU.OnSample = emu.pushSample
+
+	return func() {
+		for i, o := range emu.audio {
+			if o == out {
+				emu.audio = append(emu.audio[:i], emu.audio[i+1:]...)
+				break
+			}
+		}
+
+		if len(emu.audio) == 0 {
+			emu.APU.OnSample = nil
+		}
 	}
 }
 
-func (emu *Emulator) Step() {
+func (emu *Emulator) pushSample(s apu.Sample) {
+	for _, out := range emu.audio {
+		out.resampler.Push(s)
+	}
+}
+
+// SetChannelAudio sends each channel's sound to its own sink after every frame, resampled to the
+// rate. Muted channels are still sent. Nil sinks stop it
+func (emu *Emulator) SetChannelAudio(sinks [4]audio.SampleSink, rate int) {
+	if sinks == [4]audio.SampleSink{} {
+		emu.channelAudio = [4]*audioOutput{}
+		emu.APU.OnChannelSamples = nil
+		return
+	}
+
+	for i, sink := range sinks {
+		emu.channelAudio[i] = newAudioOutput(sink, rate)
+	}
+	emu.APU.OnChannelSamples = func(samples [4]apu.Sample) {
+		for i, s := range samples {
+			emu.channelAudio[i].resampler.Push(s)
+		}
+	}
+}
+
+// Framebuffer returns the last frame the PPU finished drawing
+func (emu *Emulator) Framebuffer() *ppu.Framebuffer {
+	return emu.PPU.Framebuffer()
+}
+
+// RunFrame runs until the end of the current frame
+func (emu *Emulator) RunFrame() error {
+	if emu.FrameHook != nil {
+		if err := emu.FrameHook.BeforeFrame(emu); err != nil {
+			return err
+		}
+	}
+
+	// RunCycles and Step don't move the end of the frame, catch up with them so this frame isn't empty
+	if emu.frameEnd <= emu.CPU.Ticks {
+		emu.frameEnd = emu.CPU.Ticks - emu.CPU.Ticks%CyclesPerFrame
+	}
+
+	emu.frameEnd += CyclesPerFrame
+	for emu.CPU.Ticks < emu.frameEnd {
+		if err := emu.Step(); err != nil {
+			return err
+		}
+	}
+
+	emu.frames++
+	for _, out := range emu.audio {
+		if err := out.write(); err != nil {
+			return err
+		}
+	}
+	for _, out := range emu.channelAudio {
+		if out == nil || out.sink == nil {
+			continue
+		}
+		if err := out.write(); err != nil {
+			return err
+		}
+	}
+
+	if emu.rewind != nil {
+		if err := emu.rewind.snapshot(emu); err != nil {
+			return err
+		}
+	}
+
+	if emu.FrameHook != nil {
+		return emu.FrameHook.AfterFrame(emu)
+	}
+
+	return nil
+}
+
+// Step runs a single instruction (or a cycle while halted), returning an *errs.EmulationError if it
+// faulted. Panics that aren't emulation faults are bugs, they're returned wrapping errs.ErrorInternal
+// so the frontends can still save and exit
+func (emu *Emulator) Step() (err error) {
+	pc := emu.CPU.Registers.PC
+	defer func() {
+		if r := recover(); r != nil {
+			fault, ok := r.(error)
+			if !ok || !errs.IsFault(fault) {
+				fault = errs.NewInternalError(r)
+			}
+			err = emu.fault(pc, fault)
+		}
+	}()
+
+	if emu.CPU.Locked {
+		emu.CPU.EmulateCycles(1)
+		return nil
+	}
+
 	emu.CPU.HandleInterrupts()
 
 	if !emu.CPU.Halted {
-		// fmt.Printf("A:%02X F:%02X B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X SP:%04X PC:%04X PCMEM:%02X,%02X,%02X,%02X\n", emu.CPU.Registers.A, emu.CPU.Registers.F, emu.CPU.Registers.B, emu.CPU.Registers.C, emu.CPU.Registers.D, emu.CPU.Registers.E, emu.CPU.Registers.H, emu.CPU.Registers.L, emu.CPU.Registers.SP, emu.CPU.Registers.PC, emu.CPU.MMU.Read8(emu.CPU.Registers.PC), emu.CPU.MMU.Read8(emu.CPU.Registers.PC+1), emu.CPU.MMU.Read8(emu.CPU.Registers.PC+2), emu.CPU.MMU.Read8(emu.CPU.Registers.PC+3))
+		if emu.Tracer != nil {
+			emu.Tracer.Trace(emu.CPU)
+		}
+
+		pc = emu.CPU.Registers.PC
 		_, instruction := emu.CPU.NextInstruction()
 		// debug.Instruction(currentPC, currentSP, opcode, instruction)
-		// emu.CPU.MMU.DebugMem()
+		// emu.MMU.DebugMem()
 
 		// debug.CPU(emu.CPU)
-		emu.CPU.MMU.DebugSerial()
 		instruction.Execute(emu.CPU)
 	} else {
 		// basically a noop
@@ -42,4 +326,27 @@ func (emu *Emulator) Step() {
 			emu.CPU.Halted = false
 		}
 	}
+
+	return nil
+}
+
+// fault adds where it happened to a recovered fault
+func (emu *Emulator) fault(pc uint16, err error) error {
+	bank := 0
+	if pc >= 0x4000 && pc < 0x8000 && emu.Cartridge != nil {
+		bank = emu.Cartridge.ROMBank()
+	}
+
+	return errs.NewEmulationError(pc, emu.peek(pc), bank, err)
+}
+
+// peek reads from the bus without side effects on the emulated cycles, 0xFF if the address can't be read
+func (emu *Emulator) peek(address uint16) (val byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			val = 0xFF
+		}
+	}()
+
+	return emu.CPU.Bus.Read8(address)
 }
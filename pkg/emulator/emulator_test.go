@@ -0,0 +1,246 @@
+package emulator_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/robherley/go-gameboy/internal/testutil"
+	"github.com/robherley/go-gameboy/pkg/apu"
+	"github.com/robherley/go-gameboy/pkg/audio"
+	"github.com/robherley/go-gameboy/pkg/cpu"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	errs "github.com/robherley/go-gameboy/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIllegalOpcode(t *testing.T) {
+	// NOP, then an illegal opcode
+	emu := testutil.Emulator(t, 0x00, 0xD3)
+
+	require.NoError(t, emu.RunFrames(context.Background(), 1))
+	assert.True(t, emu.CPU.Locked)
+	assert.Equal(t, uint16(0x102), emu.CPU.Registers.PC)
+}
+
+func TestEchoRAM(t *testing.T) {
+	// write through the mirror and read it back from work ram, then read the unusable region
+	emu := testutil.Emulator(t,
+		0x3E, 0x42, // LD A,$42
+		0xEA, 0x10, 0xE0, // LD ($E010),A
+		0xFA, 0x10, 0xC0, // LD A,($C010)
+		0x47,             // LD B,A
+		0xFA, 0xA0, 0xFE, // LD A,($FEA0)
+		0x18, 0xFE, // JR -2
+	)
+
+	require.NoError(t, emu.RunCycles(context.Background(), 100))
+	assert.Equal(t, byte(0x42), emu.CPU.Registers.B)
+	assert.Equal(t, byte(0x00), emu.CPU.Registers.A)
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		emu.MMU.Write8(0xE010, emu.MMU.Read8(0xE010))
+	}))
+
+	emu = testutil.Emulator(t, 0xEA, 0x10, 0xE0)
+	emu.SetPolicy(emulator.PolicyStrict)
+	assert.ErrorIs(t, emu.RunCycles(context.Background(), 100), errs.ErrorInvalidAddress)
+}
+
+func TestOAMDMA(t *testing.T) {
+	// copy C000-C09F to OAM, reading OAM while it's running
+	emu := testutil.Emulator(t,
+		0x3E, 0xAB, // LD A,$AB
+		0xEA, 0x05, 0xC0, // LD ($C005),A
+		0x3E, 0xC0, // LD A,$C0
+		0xE0, 0x46, // LDH ($46),A
+		0xFA, 0x05, 0xFE, // LD A,($FE05)
+		0x47,       // LD B,A
+		0x18, 0xFE, // JR -2
+	)
+
+	require.NoError(t, emu.RunCycles(context.Background(), 1000))
+	assert.Equal(t, byte(0xFF), emu.CPU.Registers.B)
+	assert.Equal(t, byte(0xAB), emu.MMU.Read8(0xFE05))
+	assert.Equal(t, byte(0xC0), emu.MMU.Read8(0xFF46))
+}
+
+func TestAudio(t *testing.T) {
+	emu := testutil.Emulator(t, 0x18, 0xFE)
+
+	samples := 0
+	remove := emu.AddAudio(audio.SinkFunc(func(s []apu.Sample) error {
+		samples += len(s)
+		return nil
+	}), audio.Rate48000)
+
+	// a second of emulated time
+	require.NoError(t, emu.RunFrames(context.Background(), 60))
+	assert.InDelta(t, 48000*60/emulator.FrameRate, samples, 1)
+
+	remove()
+	require.NoError(t, emu.RunFrames(context.Background(), 1))
+	assert.InDelta(t, 48000*60/emulator.FrameRate, samples, 1)
+}
+
+// queue plays samples at a fixed rate, it asks for more when they're running out
+type queue struct {
+	queued, capacity int
+}
+
+func (q *queue) WriteSamples(samples []apu.Sample) error {
+	q.queued += len(samples)
+	return nil
+}
+
+func (q *queue) RateAdjust() float64 {
+	return audio.DynamicRate(q.queued, q.capacity)
+}
+
+func TestAudioRateControl(t *testing.T) {
+	tests := []struct {
+		name   string
+		queued int
+	}{
+		{"nearly empty", 800},
+		{"nearly full", 4000},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			emu := testutil.Emulator(t, 0x18, 0xFE)
+			q := &queue{queued: tc.queued, capacity: 4800}
+			emu.AddAudio(q, audio.Rate48000)
+
+			// the sound card plays at exactly the emulator's rate
+			perFrame := audio.Rate48000 / emulator.FrameRate
+			played := 0.0
+			for i := 0; i < 60; i++ {
+				require.NoError(t, emu.RunFrame())
+				played += perFrame
+				q.queued -= int(played)
+				played -= float64(int(played))
+			}
+
+			// it moves toward half full
+			assert.InDelta(t, 2400, q.queued, math.Abs(float64(2400-tc.queued))-40)
+		})
+	}
+}
+
+func TestChannelAudio(t *testing.T) {
+	emu := testutil.Emulator(t, 0x18, 0xFE)
+
+	var sinks [4]audio.SampleSink
+	counts := make([]int, 4)
+	for i := range sinks {
+		i := i
+		sinks[i] = audio.SinkFunc(func(s []apu.Sample) error {
+			counts[i] += len(s)
+			return nil
+		})
+	}
+	emu.SetChannelAudio(sinks, audio.Rate44100)
+
+	require.NoError(t, emu.RunFrames(context.Background(), 60))
+	for _, n := range counts {
+		assert.InDelta(t, 44100*60/emulator.FrameRate, n, 1)
+	}
+
+	emu.SetChannelAudio([4]audio.SampleSink{}, 0)
+	require.NoError(t, emu.RunFrames(context.Background(), 1))
+	assert.InDelta(t, 44100*60/emulator.FrameRate, counts[0], 1)
+}
+
+func TestStepFault(t *testing.T) {
+	// NOP, then an illegal opcode
+	emu := testutil.Emulator(t, 0x00, 0xD3)
+	emu.SetPolicy(emulator.PolicyStrict)
+
+	err := emu.RunFrames(context.Background(), 1)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errs.ErrorIllegalInstruction)
+
+	var fault *errs.EmulationError
+	require.True(t, errors.As(err, &fault))
+	assert.Equal(t, uint16(0x101), fault.PC)
+	assert.Equal(t, byte(0xD3), fault.Opcode)
+	assert.Equal(t, 0, fault.Bank)
+}
+
+func TestRunCycles(t *testing.T) {
+	// JR -2, loops forever
+	emu := testutil.Emulator(t, 0x18, 0xFE)
+
+	require.NoError(t, emu.RunCycles(context.Background(), 1000))
+	// each jump is 12 T-cycles, the last one finishes past the budget
+	assert.Equal(t, uint64(1008), emu.CPU.Ticks)
+
+	require.NoError(t, emu.RunFrames(context.Background(), 2))
+	assert.GreaterOrEqual(t, emu.CPU.Ticks, uint64(2*emulator.CyclesPerFrame))
+}
+
+func TestRunFramesAfterCycles(t *testing.T) {
+	emu := testutil.Emulator(t, 0x18, 0xFE)
+
+	require.NoError(t, emu.RunCycles(context.Background(), 3*emulator.CyclesPerFrame+100))
+	require.NoError(t, emu.RunFrames(context.Background(), 1))
+	// the frame that RunCycles stopped in is finished, rather than running nothing
+	assert.GreaterOrEqual(t, emu.CPU.Ticks, uint64(4*emulator.CyclesPerFrame))
+	assert.Less(t, emu.CPU.Ticks, uint64(4*emulator.CyclesPerFrame+12))
+
+	require.NoError(t, emu.RunFrames(context.Background(), 1))
+	assert.GreaterOrEqual(t, emu.CPU.Ticks, uint64(5*emulator.CyclesPerFrame))
+}
+
+func TestRunCancel(t *testing.T) {
+	emu := testutil.Emulator(t, 0x18, 0xFE)
+
+	frames := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	emu.OnFrame = func() {
+		frames++
+		if frames == 3 {
+			cancel()
+		}
+	}
+
+	err := emu.Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 3, frames)
+
+	frames = 0
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	assert.ErrorIs(t, emu.RunFrames(ctx, 100), context.Canceled)
+	assert.Equal(t, 3, frames)
+
+	ticks := emu.CPU.Ticks
+	assert.ErrorIs(t, emu.RunCycles(ctx, 1_000_000), context.Canceled)
+	assert.Equal(t, ticks, emu.CPU.Ticks, "cancelled before running")
+}
+
+// panicTracer panics like a bug in the emulator would
+type panicTracer struct{}
+
+func (panicTracer) Trace(c *cpu.CPU) {
+	var regs *cpu.Registers
+	_ = regs.A
+}
+
+func TestStepBug(t *testing.T) {
+	emu := testutil.Emulator(t, 0x00)
+	emu.Trace(panicTracer{}, false)
+
+	var err error
+	assert.NotPanics(t, func() {
+		err = emu.Step()
+	})
+	assert.ErrorIs(t, err, errs.ErrorInternal)
+	assert.False(t, errs.IsFault(err), "bugs aren't faults of the emulated hardware")
+
+	var fault *errs.EmulationError
+	require.True(t, errors.As(err, &fault))
+	assert.Equal(t, uint16(0x100), fault.PC)
+}
@@ -0,0 +1,118 @@
+package emulator
+
+import "time"
+
+const (
+	// ClockSpeed is the number of T-cycles per second
+	ClockSpeed = 4194304
+	// CyclesPerFrame is the number of T-cycles to draw a frame, 154 scanlines of 456 cycles
+	// https://gbdev.io/pandocs/Rendering.html#frame-timing
+	CyclesPerFrame = 70224
+	// FrameRate is the number of frames per second, ~59.7275
+	FrameRate = float64(ClockSpeed) / CyclesPerFrame
+
+	MinSpeed float64 = 0.25
+	MaxSpeed float64 = 10
+
+	// MaxFrameSkip is the number of frames that can be skipped in a row when behind
+	MaxFrameSkip = 4
+)
+
+// frameDuration is the real time a frame takes at 1x speed
+const frameDuration = CyclesPerFrame * time.Second / ClockSpeed
+
+// Pacer keeps emulated frames in step with the host's monotonic clock
+type Pacer struct {
+	// Now and Sleep default to the time package, they can be replaced for testing
+	Now   func() time.Time
+	Sleep func(time.Duration)
+
+	speed       float64
+	unthrottled bool
+	// when pacing started, frames are scheduled relative to it so rounding errors don't accumulate
+	start  time.Time
+	frames int64
+	// number of frames skipped in a row
+	skipped int
+}
+
+func NewPacer() *Pacer {
+	return &Pacer{
+		Now:   time.Now,
+		Sleep: time.Sleep,
+		speed: 1,
+	}
+}
+
+// Speed returns the speed multiplier
+func (p *Pacer) Speed() float64 {
+	return p.speed
+}
+
+// SetSpeed sets the speed multiplier, clamped to MinSpeed-MaxSpeed
+func (p *Pacer) SetSpeed(speed float64) {
+	if speed < MinSpeed {
+		speed = MinSpeed
+	} else if speed > MaxSpeed {
+		speed = MaxSpeed
+	}
+
+	p.speed = speed
+	p.Reset()
+}
+
+// Unthrottled checks if frames are run as fast as possible
+func (p *Pacer) Unthrottled() bool {
+	return p.unthrottled
+}
+
+// SetUnthrottled disables pacing, for tests and fast forwarding
+func (p *Pacer) SetUnthrottled(unthrottled bool) {
+	p.unthrottled = unthrottled
+	p.Reset()
+}
+
+// Reset starts pacing from the next frame, ie: after the emulator was paused
+func (p *Pacer) Reset() {
+	p.start = time.Time{}
+	p.frames = 0
+	p.skipped = 0
+}
+
+// Wait is called after each frame is emulated, blocking until it's due.
+// It returns false if the host has fallen behind and the frame shouldn't be presented
+func (p *Pacer) Wait() bool {
+	if p.unthrottled {
+		return true
+	}
+
+	now := p.Now()
+	if p.start.IsZero() {
+		p.start = now
+	}
+
+	p.frames++
+	frame := time.Duration(float64(frameDuration) / p.speed)
+	due := p.start.Add(time.Duration(p.frames) * frame)
+
+	if wait := due.Sub(now); wait > 0 {
+		p.skipped = 0
+		p.Sleep(wait)
+		return true
+	}
+
+	// running behind, skip presenting frames to catch up
+	if now.Sub(due) > frame && p.skipped < MaxFrameSkip {
+		p.skipped++
+		return false
+	}
+
+	// too far behind to catch up, start over from now instead of running fast
+	if p.skipped == MaxFrameSkip {
+		p.start = now
+		p.frames = 0
+	}
+
+	p.skipped = 0
+	return true
+}
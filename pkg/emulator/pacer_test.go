@@ -0,0 +1,76 @@
+package emulator_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock only advances when slept on, or when the host is pretending to be slow
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func newPacer() (*emulator.Pacer, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	p := emulator.NewPacer()
+	p.Now = func() time.Time { return clock.now }
+	p.Sleep = func(d time.Duration) {
+		clock.slept += d
+		clock.now = clock.now.Add(d)
+	}
+	return p, clock
+}
+
+func TestPacerFrameRate(t *testing.T) {
+	cases := []struct {
+		speed    float64
+		expected float64
+	}{
+		{1, 1},
+		{2, 0.5},
+		{0.25, 4},
+		{0.1, 4},
+		{100, 0.1},
+	}
+
+	for _, tc := range cases {
+		p, clock := newPacer()
+		p.SetSpeed(tc.speed)
+
+		for i := 0; i < 60; i++ {
+			assert.True(t, p.Wait())
+		}
+
+		expected := tc.expected * 60 / emulator.FrameRate
+		assert.InDelta(t, expected, clock.slept.Seconds(), 0.001, "speed %g", tc.speed)
+	}
+}
+
+func TestPacerUnthrottled(t *testing.T) {
+	p, clock := newPacer()
+	p.SetUnthrottled(true)
+
+	for i := 0; i < 60; i++ {
+		assert.True(t, p.Wait())
+	}
+	assert.Zero(t, clock.slept)
+}
+
+func TestPacerFrameSkip(t *testing.T) {
+	p, clock := newPacer()
+	assert.True(t, p.Wait())
+
+	// host stalls for a second, frames are skipped up to the limit and then the clock resyncs
+	clock.now = clock.now.Add(time.Second)
+	presented := []bool{}
+	for i := 0; i < emulator.MaxFrameSkip+2; i++ {
+		presented = append(presented, p.Wait())
+	}
+
+	assert.Equal(t, []bool{false, false, false, false, true, true}, presented)
+	assert.Greater(t, clock.slept, time.Duration(0))
+}
@@ -0,0 +1,245 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Rewinding keeps a save state every few frames. Only the newest is kept in full, older ones are
+// the XOR of themselves and the next newest, run length encoded. Between frames very little
+// changes, so the deltas are mostly runs of zeros and compress to almost nothing.
+
+const (
+	DefaultRewindInterval = 4
+	// DefaultRewindBudget is enough for a few minutes of most games
+	DefaultRewindBudget = 32 << 20
+)
+
+// ErrorNoRewind is returned when rewinding without any snapshots to go back to
+var ErrorNoRewind = errors.New("nothing to rewind to")
+
+type RewindOptions struct {
+	// Interval is the number of frames between snapshots
+	Interval int
+	// Budget is the maximum number of bytes kept, the oldest snapshots are dropped to stay under it
+	Budget int
+}
+
+type rewind struct {
+	opts RewindOptions
+	// the newest snapshot in full, and the frame it was taken on
+	current      []byte
+	currentFrame uint64
+	// older snapshots, oldest first
+	deltas deltaRing
+	size   int
+}
+
+type rewindDelta struct {
+	frame uint64
+	// length of the snapshot, they can differ if the state format changes size
+	length int
+	data   []byte
+}
+
+// EnableRewind starts taking snapshots to rewind to, zero options use the defaults
+func (emu *Emulator) EnableRewind(opts RewindOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultRewindInterval
+	}
+	if opts.Budget <= 0 {
+		opts.Budget = DefaultRewindBudget
+	}
+
+	emu.rewind = &rewind{opts: opts}
+}
+
+// DisableRewind stops taking snapshots and frees the existing ones
+func (emu *Emulator) DisableRewind() {
+	emu.rewind = nil
+}
+
+// Frame returns the number of frames run
+func (emu *Emulator) Frame() uint64 {
+	return emu.frames
+}
+
+// Rewind goes back at least the number of frames, to the closest snapshot. If there isn't one that
+// far back it goes to the oldest. It returns the number of frames actually rewound
+func (emu *Emulator) Rewind(frames int) (int, error) {
+	rw := emu.rewind
+	if rw == nil || rw.current == nil {
+		return 0, ErrorNoRewind
+	}
+
+	target := uint64(0)
+	if uint64(frames) < emu.frames {
+		target = emu.frames - uint64(frames)
+	}
+
+	state := rw.current
+	frame := rw.currentFrame
+	// the newest snapshot is only good if it's far enough back, ie: not the frame that just ran
+	for frame > target && rw.deltas.length > 0 {
+		delta := rw.deltas.popNewest()
+		rw.size -= len(delta.data)
+
+		state = applyDelta(state, delta)
+		frame = delta.frame
+	}
+
+	if err := emu.LoadState(bytes.NewReader(state)); err != nil {
+		return 0, fmt.Errorf("unable to rewind: %w", err)
+	}
+
+	rewound := int(emu.frames - frame)
+	emu.frames = frame
+
+	rw.size += len(state) - len(rw.current)
+	rw.current, rw.currentFrame = state, frame
+	return rewound, nil
+}
+
+// snapshot is called after each frame, taking a snapshot every interval
+func (rw *rewind) snapshot(emu *Emulator) error {
+	if emu.frames%uint64(rw.opts.Interval) != 0 {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	if err := emu.SaveState(buf); err != nil {
+		return err
+	}
+	state := buf.Bytes()
+
+	if rw.current != nil {
+		delta := rewindDelta{
+			frame:  rw.currentFrame,
+			length: len(rw.current),
+			data:   encodeDelta(rw.current, state),
+		}
+		rw.deltas.push(delta)
+		rw.size += len(delta.data) - len(rw.current)
+	}
+
+	rw.current, rw.currentFrame = state, emu.frames
+	rw.size += len(state)
+
+	// drop the oldest to stay under budget
+	for rw.size > rw.opts.Budget && rw.deltas.length > 0 {
+		rw.size -= len(rw.deltas.popOldest().data)
+	}
+
+	return nil
+}
+
+// deltaRing is a queue of deltas, oldest first. It only grows while the rewind buffer fills up to
+// its budget, after that the oldest are dropped and their slots reused for the newest
+type deltaRing struct {
+	deltas []rewindDelta
+	// index of the oldest delta, and the number of deltas
+	head, length int
+}
+
+func (r *deltaRing) push(delta rewindDelta) {
+	if r.length == len(r.deltas) {
+		grown := make([]rewindDelta, 2*len(r.deltas)+16)
+		for i := 0; i < r.length; i++ {
+			grown[i] = r.deltas[(r.head+i)%len(r.deltas)]
+		}
+		r.deltas, r.head = grown, 0
+	}
+
+	r.deltas[(r.head+r.length)%len(r.deltas)] = delta
+	r.length++
+}
+
+func (r *deltaRing) popOldest() rewindDelta {
+	delta := r.deltas[r.head]
+	// release the data, the slot is only overwritten when the ring wraps around to it
+	r.deltas[r.head] = rewindDelta{}
+	r.head = (r.head + 1) % len(r.deltas)
+	r.length--
+	return delta
+}
+
+func (r *deltaRing) popNewest() rewindDelta {
+	i := (r.head + r.length - 1) % len(r.deltas)
+	delta := r.deltas[i]
+	r.deltas[i] = rewindDelta{}
+	r.length--
+	return delta
+}
+
+// encodeDelta XORs the older snapshot with the newer one, then run length encodes it as pairs of
+// uvarints for the number of zeros and the number of literal bytes, followed by the literals
+func encodeDelta(older, newer []byte) []byte {
+	out := []byte{}
+	tmp := make([]byte, binary.MaxVarintLen64)
+
+	xor := func(i int) byte {
+		var a, b byte
+		if i < len(older) {
+			a = older[i]
+		}
+		if i < len(newer) {
+			b = newer[i]
+		}
+		return a ^ b
+	}
+
+	length := len(older)
+	if len(newer) > length {
+		length = len(newer)
+	}
+
+	for i := 0; i < length; {
+		zeros := 0
+		for i < length && xor(i) == 0 {
+			zeros++
+			i++
+		}
+
+		start := i
+		for i < length && xor(i) != 0 {
+			i++
+		}
+
+		out = append(out, tmp[:binary.PutUvarint(tmp, uint64(zeros))]...)
+		out = append(out, tmp[:binary.PutUvarint(tmp, uint64(i-start))]...)
+		for j := start; j < i; j++ {
+			out = append(out, xor(j))
+		}
+	}
+
+	return out
+}
+
+// applyDelta recovers the older snapshot from the newer one
+func applyDelta(newer []byte, delta rewindDelta) []byte {
+	length := len(newer)
+	if delta.length > length {
+		length = delta.length
+	}
+
+	older := make([]byte, length)
+	copy(older, newer)
+
+	r := bytes.NewReader(delta.data)
+	i := 0
+	for r.Len() > 0 {
+		zeros, _ := binary.ReadUvarint(r)
+		literals, _ := binary.ReadUvarint(r)
+		i += int(zeros)
+
+		for j := uint64(0); j < literals; j++ {
+			b, _ := r.ReadByte()
+			older[i] ^= b
+			i++
+		}
+	}
+
+	return older[:delta.length]
+}
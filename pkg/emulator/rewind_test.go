@@ -0,0 +1,92 @@
+package emulator_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/robherley/go-gameboy/internal/testutil"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewind(t *testing.T) {
+	emu := testutil.Emulator(t, counter...)
+	emu.EnableRewind(emulator.RewindOptions{Interval: 2})
+
+	_, err := emu.Rewind(1)
+	assert.ErrorIs(t, err, emulator.ErrorNoRewind)
+
+	// remember the state at every frame to compare against
+	states := map[uint64][]byte{}
+	emu.OnFrame = func() {
+		buf := &bytes.Buffer{}
+		require.NoError(t, emu.SaveState(buf))
+		states[emu.Frame()] = buf.Bytes()
+	}
+	require.NoError(t, emu.RunFrames(context.Background(), 20))
+
+	cases := []struct {
+		frames  int
+		rewound int
+	}{
+		// frame 20 -> 18
+		{1, 2},
+		// frame 18 -> 12
+		{5, 6},
+		// only back to the first snapshot, frame 12 -> 2
+		{100, 10},
+	}
+
+	for _, tc := range cases {
+		rewound, err := emu.Rewind(tc.frames)
+		require.NoError(t, err)
+		assert.Equal(t, tc.rewound, rewound)
+
+		buf := &bytes.Buffer{}
+		require.NoError(t, emu.SaveState(buf))
+		assert.Equal(t, states[emu.Frame()], buf.Bytes(), "frame %d", emu.Frame())
+	}
+
+	// history continues from where it was rewound to
+	require.NoError(t, emu.RunFrames(context.Background(), 4))
+	rewound, err := emu.Rewind(3)
+	require.NoError(t, err)
+	assert.Equal(t, 4, rewound)
+	assert.Equal(t, uint64(2), emu.Frame())
+}
+
+func TestRewindBudget(t *testing.T) {
+	emu := testutil.Emulator(t, counter...)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, emu.SaveState(buf))
+
+	// room for the current snapshot and a few small deltas
+	emu.EnableRewind(emulator.RewindOptions{Interval: 1, Budget: buf.Len() + 200})
+
+	states := map[uint64][]byte{}
+	emu.OnFrame = func() {
+		buf := &bytes.Buffer{}
+		require.NoError(t, emu.SaveState(buf))
+		states[emu.Frame()] = buf.Bytes()
+	}
+	require.NoError(t, emu.RunFrames(context.Background(), 100))
+
+	// the oldest have been dropped many times over, the rest still go back in order
+	for i := 0; i < 3; i++ {
+		rewound, err := emu.Rewind(1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, rewound)
+
+		buf := &bytes.Buffer{}
+		require.NoError(t, emu.SaveState(buf))
+		assert.Equal(t, states[emu.Frame()], buf.Bytes(), "frame %d", emu.Frame())
+	}
+
+	rewound, err := emu.Rewind(100)
+	require.NoError(t, err)
+	assert.Greater(t, rewound, 0)
+	assert.Less(t, rewound, 96)
+}
@@ -0,0 +1,247 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/robherley/go-gameboy/pkg/cpu"
+	errs "github.com/robherley/go-gameboy/pkg/errors"
+	"github.com/robherley/go-gameboy/pkg/interrupt"
+)
+
+// Save states start with a header, then a sequence of chunks that each have a 4 character id,
+// a little endian uint32 length and the data. Every chunk here is required, chunks that aren't
+// known are skipped when loading.
+//
+//	"GBSS" | version (uint16) | chunk... | "END " chunk
+
+const (
+	stateMagic = "GBSS"
+	// StateVersion is bumped when the contents of an existing chunk change
+	StateVersion uint16 = 1
+
+	// chunks are never anywhere near this large, it guards against allocating for corrupt states
+	maxChunkSize = 1 << 24
+)
+
+const (
+	// crc32 of the rom the state belongs to
+	chunkInfo = "INFO"
+	// registers, halted, locked and the cycle count
+	chunkCPU = "CPU "
+	// IME, the EI/DI delays, IF and IE
+	chunkInterrupt = "INT "
+	// timer registers and the reload delay
+	chunkTimer = "TIMR"
+	// wram, hram, serial and oam dma
+	chunkMemory = "MEM "
+	// lcd registers, vram, oam and the framebuffers
+	chunkPPU = "PPU "
+	// mbc registers and cartridge ram
+	chunkCartridge = "CART"
+	// P1 selection
+	chunkJoypad = "JOYP"
+	// sound registers, wave ram and channel state
+	chunkAPU = "APU "
+	chunkEnd = "END "
+)
+
+type cpuState struct {
+	Halted bool
+	Locked bool
+	Ticks  uint64
+}
+
+// SaveState writes a snapshot of the machine to w
+func (emu *Emulator) SaveState(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString(stateMagic)
+	binary.Write(buf, binary.LittleEndian, StateVersion)
+
+	chunks := []struct {
+		id   string
+		data encoding.BinaryMarshaler
+	}{
+		{chunkInfo, fixed{emu.Cartridge.Checksum()}},
+		{chunkCPU, fixed{emu.CPU.Registers, cpuState{emu.CPU.Halted, emu.CPU.Locked, emu.CPU.Ticks}}},
+		{chunkInterrupt, fixed{emu.CPU.Interrupt}},
+		{chunkTimer, emu.timer},
+		{chunkMemory, emu.MMU},
+		{chunkPPU, emu.PPU},
+		{chunkCartridge, emu.Cartridge},
+		{chunkJoypad, emu.Joypad},
+		{chunkAPU, emu.APU},
+		{chunkEnd, fixed{}},
+	}
+
+	for _, chunk := range chunks {
+		data, err := chunk.data.MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString(chunk.id)
+		binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+		buf.Write(data)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// LoadState restores a snapshot from SaveState. The emulator is left unchanged if the state is
+// for a different rom, an unsupported version or any of its chunks are corrupt
+func (emu *Emulator) LoadState(r io.Reader) error {
+	chunks, err := readChunks(r)
+	if err != nil {
+		return err
+	}
+
+	var checksum uint32
+	if err := unmarshalFixed(chunks[chunkInfo], &checksum); err != nil {
+		return err
+	}
+	if checksum != emu.Cartridge.Checksum() {
+		return fmt.Errorf("%w: state is for a different rom (checksum %08X, want %08X)", errs.ErrorInvalidState, checksum, emu.Cartridge.Checksum())
+	}
+
+	for _, id := range []string{chunkCPU, chunkInterrupt, chunkTimer, chunkMemory, chunkPPU, chunkCartridge, chunkJoypad, chunkAPU} {
+		if _, ok := chunks[id]; !ok {
+			return fmt.Errorf("%w: missing %q chunk", errs.ErrorInvalidState, id)
+		}
+	}
+
+	regs, state, inter := cpu.Registers{}, cpuState{}, interrupt.Interrupt{}
+	if err := unmarshalFixed(chunks[chunkCPU], &regs, &state); err != nil {
+		return err
+	}
+	if err := unmarshalFixed(chunks[chunkInterrupt], &inter); err != nil {
+		return err
+	}
+
+	components := []struct {
+		id   string
+		data component
+	}{
+		{chunkTimer, emu.timer},
+		{chunkMemory, emu.MMU},
+		{chunkPPU, emu.PPU},
+		{chunkCartridge, emu.Cartridge},
+		{chunkJoypad, emu.Joypad},
+		{chunkAPU, emu.APU},
+	}
+
+	// the components check their chunk sizes as they load, keep a copy of each one so a bad chunk
+	// late in the state doesn't leave the earlier ones restored
+	backups := make([][]byte, len(components))
+	for i, component := range components {
+		if backups[i], err = component.data.MarshalBinary(); err != nil {
+			return err
+		}
+	}
+
+	for i, component := range components {
+		if err := component.data.UnmarshalBinary(chunks[component.id]); err != nil {
+			for j := 0; j <= i; j++ {
+				// the backups came from the components themselves, they always load
+				components[j].data.UnmarshalBinary(backups[j])
+			}
+			return err
+		}
+	}
+
+	*emu.CPU.Registers = regs
+	*emu.CPU.Interrupt = inter
+	emu.CPU.Halted, emu.CPU.Locked, emu.CPU.Ticks = state.Halted, state.Locked, state.Ticks
+	// frames end on multiples of CyclesPerFrame, the last instruction of a frame runs slightly past it
+	emu.frameEnd = state.Ticks - state.Ticks%CyclesPerFrame
+
+	emu.Pacer.Reset()
+	return nil
+}
+
+// component is a part of the machine that's saved in its own chunk
+type component interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// readChunks reads the header and chunks of a save state, keyed by id
+func readChunks(r io.Reader) (map[string][]byte, error) {
+	header := make([]byte, len(stateMagic)+2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrorInvalidState, err)
+	}
+
+	if string(header[:len(stateMagic)]) != stateMagic {
+		return nil, fmt.Errorf("%w: not a save state", errs.ErrorInvalidState)
+	}
+
+	if version := binary.LittleEndian.Uint16(header[len(stateMagic):]); version != StateVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", errs.ErrorInvalidState, version)
+	}
+
+	chunks := map[string][]byte{}
+	for {
+		id := make([]byte, 4)
+		var size uint32
+		if _, err := io.ReadFull(r, id); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrorInvalidState, err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrorInvalidState, err)
+		}
+
+		if size > maxChunkSize {
+			return nil, fmt.Errorf("%w: %q chunk is too large", errs.ErrorInvalidState, id)
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrorInvalidState, err)
+		}
+
+		if string(id) == chunkEnd {
+			return chunks, nil
+		}
+
+		chunks[string(id)] = data
+	}
+}
+
+// fixed encodes fixed size values (structs of bools, bytes and ints) for chunks
+type fixed []any
+
+func (f fixed) MarshalBinary() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, v := range f {
+		if err := binary.Write(buf, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// unmarshalFixed decodes a chunk of fixed size values, the chunk must be exactly their size
+func unmarshalFixed(data []byte, values ...any) error {
+	r := bytes.NewReader(data)
+	for _, v := range values {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("%w: chunk is too small", errs.ErrorInvalidState)
+			}
+			return err
+		}
+	}
+
+	if r.Len() != 0 {
+		return fmt.Errorf("%w: chunk is too large", errs.ErrorInvalidState)
+	}
+
+	return nil
+}
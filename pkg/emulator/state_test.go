@@ -0,0 +1,181 @@
+package emulator_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/robherley/go-gameboy/internal/testutil"
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	errs "github.com/robherley/go-gameboy/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counter increments B and stores it in wram and hram forever
+var counter = []byte{
+	0x04,             // INC B
+	0x78,             // LD A,B
+	0xEA, 0x00, 0xC0, // LD ($C000),A
+	0xE0, 0x80, // LDH ($FF80),A
+	0x18, 0xF8, // JR -8
+}
+
+func TestSaveState(t *testing.T) {
+	emu := testutil.Emulator(t, counter...)
+	require.NoError(t, emu.RunCycles(context.Background(), 10000))
+
+	state := &bytes.Buffer{}
+	require.NoError(t, emu.SaveState(state))
+	regs, ticks := *emu.CPU.Registers, emu.CPU.Ticks
+	wram := emu.MMU.Read8(0xC000)
+
+	require.NoError(t, emu.RunCycles(context.Background(), 10000))
+	assert.NotEqual(t, regs, *emu.CPU.Registers)
+
+	require.NoError(t, emu.LoadState(bytes.NewReader(state.Bytes())))
+	assert.Equal(t, regs, *emu.CPU.Registers)
+	assert.Equal(t, ticks, emu.CPU.Ticks)
+	assert.Equal(t, wram, emu.MMU.Read8(0xC000))
+	assert.Equal(t, wram, emu.MMU.Read8(0xFF80))
+
+	// a fresh emulator ends up in the same place
+	other := testutil.Emulator(t, counter...)
+	require.NoError(t, other.LoadState(bytes.NewReader(state.Bytes())))
+	require.NoError(t, emu.RunCycles(context.Background(), 5000))
+	require.NoError(t, other.RunCycles(context.Background(), 5000))
+	assert.Equal(t, *emu.CPU.Registers, *other.CPU.Registers)
+}
+
+func TestLoadStateErrors(t *testing.T) {
+	emu := testutil.Emulator(t, counter...)
+	state := &bytes.Buffer{}
+	require.NoError(t, emu.SaveState(state))
+	data := state.Bytes()
+
+	newer := append([]byte{}, data...)
+	newer[4] = 0xFF
+
+	// renamed to a chunk that isn't known, which is skipped
+	missing := bytes.Replace(data, []byte("JOYP"), []byte("XXXX"), 1)
+
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not a state", []byte("GBSX\x01\x00")},
+		{"newer version", newer},
+		{"missing chunk", missing},
+		{"truncated", data[:len(data)-10]},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, emu.LoadState(bytes.NewReader(tc.data)), errs.ErrorInvalidState)
+		})
+	}
+
+	t.Run("different rom", func(t *testing.T) {
+		other := testutil.Emulator(t, 0x18, 0xFE)
+		require.NoError(t, other.RunCycles(context.Background(), 100))
+		pc := other.CPU.Registers.PC
+
+		assert.ErrorIs(t, other.LoadState(bytes.NewReader(data)), errs.ErrorInvalidState)
+		assert.Equal(t, pc, other.CPU.Registers.PC)
+	})
+}
+
+func TestLoadStateCorruptChunk(t *testing.T) {
+	emu := testutil.Emulator(t, counter...)
+	require.NoError(t, emu.RunCycles(context.Background(), 10000))
+
+	state := &bytes.Buffer{}
+	require.NoError(t, emu.SaveState(state))
+
+	// drop the last byte of the apu chunk, the last one before END
+	data := state.Bytes()
+	end := len(data) - 8
+	at := bytes.LastIndex(data[:end], []byte("APU "))
+	require.NotEqual(t, -1, at)
+	size := binary.LittleEndian.Uint32(data[at+4:])
+	require.Equal(t, end, at+8+int(size))
+
+	corrupt := append([]byte{}, data[:end-1]...)
+	binary.LittleEndian.PutUint32(corrupt[at+4:], size-1)
+	corrupt = append(corrupt, data[end:]...)
+
+	require.NoError(t, emu.RunCycles(context.Background(), 10000))
+	before := &bytes.Buffer{}
+	require.NoError(t, emu.SaveState(before))
+
+	assert.ErrorIs(t, emu.LoadState(bytes.NewReader(corrupt)), errs.ErrorInvalidState)
+
+	after := &bytes.Buffer{}
+	require.NoError(t, emu.SaveState(after))
+	assert.Equal(t, before.Bytes(), after.Bytes(), "nothing was restored from the corrupt state")
+}
+
+func TestBESS(t *testing.T) {
+	emu := testutil.Emulator(t, counter...)
+	require.NoError(t, emu.RunCycles(context.Background(), 10000))
+
+	state := &bytes.Buffer{}
+	require.NoError(t, emu.ExportBESS(state))
+	assert.True(t, emulator.IsBESS(state.Bytes()))
+
+	other := testutil.Emulator(t, counter...)
+	require.NoError(t, other.ImportBESS(bytes.NewReader(state.Bytes())))
+	assert.Equal(t, *emu.CPU.Registers, *other.CPU.Registers)
+	assert.Equal(t, emu.MMU.Read8(0xC000), other.MMU.Read8(0xC000))
+	assert.Equal(t, emu.MMU.Read8(0xFF80), other.MMU.Read8(0xFF80))
+	assert.Equal(t, emu.MMU.Read8(0xFF04), other.MMU.Read8(0xFF04))
+
+	t.Run("different rom", func(t *testing.T) {
+		other := testutil.Emulator(t, 0x18, 0xFE)
+		other.Cartridge.Data[0x134] = 'X'
+		assert.ErrorIs(t, other.ImportBESS(bytes.NewReader(state.Bytes())), errs.ErrorInvalidState)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		data := state.Bytes()
+		assert.ErrorIs(t, other.ImportBESS(bytes.NewReader(data[:len(data)-9])), errs.ErrorInvalidState)
+	})
+
+	t.Run("rtc without a clock", func(t *testing.T) {
+		// an RTC block in place of the END block, followed by a new END block and the footer
+		data := append([]byte{}, state.Bytes()...)
+		footer := append([]byte{}, data[len(data)-8:]...)
+		data = append(data[:len(data)-16], "RTC \x30\x00\x00\x00"...)
+		data = append(data, make([]byte, 0x30)...)
+		data = append(data, "END \x00\x00\x00\x00"...)
+		data = append(data, footer...)
+
+		pc := other.CPU.Registers.PC
+		assert.ErrorIs(t, other.ImportBESS(bytes.NewReader(data)), errs.ErrorInvalidState)
+		assert.Equal(t, pc, other.CPU.Registers.PC)
+	})
+
+	t.Run("rtc", func(t *testing.T) {
+		newMBC3 := func() *emulator.Emulator {
+			rom := testutil.ROM(counter...)
+			rom[0x147] = byte(cartridge.MBC3_TIMER_RAM_BATTERY)
+			rom[0x149] = 0x03
+			cart, err := cartridge.FromBytes(rom)
+			require.NoError(t, err)
+			return emulator.New(cart)
+		}
+
+		emu := newMBC3()
+		emu.Cartridge.SetClock(86400 + 3600)
+		state := &bytes.Buffer{}
+		require.NoError(t, emu.ExportBESS(state))
+
+		other := newMBC3()
+		require.NoError(t, other.ImportBESS(bytes.NewReader(state.Bytes())))
+		assert.GreaterOrEqual(t, other.Cartridge.Clock(), int64(86400+3600))
+		assert.Less(t, other.Cartridge.Clock(), int64(86400+3600+60), "only caught up with the time since it was saved")
+	})
+}
@@ -0,0 +1,50 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/robherley/go-gameboy/pkg/cpu"
+)
+
+// Tracer is called with the CPU state before each instruction is executed
+type Tracer interface {
+	Trace(c *cpu.CPU)
+}
+
+// DoctorTracer logs the CPU state in the format expected by gameboy-doctor
+// https://github.com/robert/gameboy-doctor
+type DoctorTracer struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func NewDoctorTracer(w io.Writer) *DoctorTracer {
+	return &DoctorTracer{
+		w: bufio.NewWriter(w),
+	}
+}
+
+func (d *DoctorTracer) Trace(c *cpu.CPU) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	r := c.Registers
+	pc := r.PC
+
+	// read directly from the bus, peeking at memory shouldn't tick the clock
+	fmt.Fprintf(d.w, "A:%02X F:%02X B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X SP:%04X PC:%04X PCMEM:%02X,%02X,%02X,%02X\n",
+		r.A, r.F, r.B, r.C, r.D, r.E, r.H, r.L, r.SP, pc,
+		c.Bus.Read8(pc), c.Bus.Read8(pc+1), c.Bus.Read8(pc+2), c.Bus.Read8(pc+3),
+	)
+}
+
+// Flush writes any buffered log lines to the underlying writer
+func (d *DoctorTracer) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.w.Flush()
+}
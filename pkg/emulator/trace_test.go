@@ -0,0 +1,38 @@
+package emulator_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/robherley/go-gameboy/internal/testutil"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoctorTracer(t *testing.T) {
+	// LD A,0x12; LDH A,(0x44) reads LY; NOP
+	emu := testutil.Emulator(t, 0x3E, 0x12, 0xF0, 0x44, 0x00)
+
+	var buf bytes.Buffer
+	tracer := emulator.NewDoctorTracer(&buf)
+	emu.Trace(tracer, true)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, emu.Step())
+	}
+	require.NoError(t, tracer.Flush())
+
+	assert.Equal(t, []string{
+		"A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:3E,12,F0,44",
+		"A:12 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0102 PCMEM:F0,44,00,00",
+		"A:90 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0104 PCMEM:00,00,00,00",
+	}, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"))
+
+	// LY is read from the lcd without it
+	emu = testutil.Emulator(t, 0xF0, 0x44)
+	emu.Trace(emulator.NewDoctorTracer(&bytes.Buffer{}), false)
+	require.NoError(t, emu.Step())
+	assert.NotEqual(t, byte(0x90), emu.CPU.Registers.A)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"runtime/debug"
 )
 
 var (
@@ -14,8 +15,32 @@ var (
 	ErrorInvalidInstruction = errors.New("invalid instruction")
 	ErrorIllegalInstruction = errors.New("illegal instruction")
 	ErrorNotImplemented     = errors.New("not implemented")
+	ErrorInvalidState       = errors.New("invalid save state")
+	ErrorInternal           = errors.New("internal error")
 )
 
+// faults are what the emulated hardware panics with
+var faults = []error{
+	ErrorInvalidAddress,
+	ErrorInvalidMnemonic,
+	ErrorInvalidOperand,
+	ErrorInvalidSymbol,
+	ErrorInvalidInstruction,
+	ErrorIllegalInstruction,
+	ErrorNotImplemented,
+}
+
+// IsFault checks if err is a fault of the emulated hardware, as opposed to a bug in the emulator
+func IsFault(err error) bool {
+	for _, fault := range faults {
+		if errors.Is(err, fault) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func NewInvalidOperandError(operand any) error {
 	return fmt.Errorf("%w: %v (%T)", ErrorInvalidOperand, operand, operand)
 }
@@ -60,6 +85,10 @@ func NewUnknownOPCodeError(opcode byte) error {
 	return fmt.Errorf("%w: unknown opcode 0x%02x", ErrorInvalidInstruction, opcode)
 }
 
+func NewInvalidStateError(component string, size int) error {
+	return fmt.Errorf("%w: unexpected %s state size %d", ErrorInvalidState, component, size)
+}
+
 func NewNotImplementedError() error {
 	caller := "unknown"
 	lineNo := 0
@@ -71,3 +100,36 @@ func NewNotImplementedError() error {
 	}
 	return fmt.Errorf("%w: called from %s:%d", ErrorNotImplemented, caller, lineNo)
 }
+
+// NewInternalError wraps a recovered panic that wasn't a fault, with the stack it panicked from
+func NewInternalError(r any) error {
+	return fmt.Errorf("%w: %v\n%s", ErrorInternal, r, debug.Stack())
+}
+
+// EmulationError is a fault while executing an instruction
+type EmulationError struct {
+	// PC is the address of the instruction
+	PC uint16
+	// Opcode is the first byte of the instruction
+	Opcode byte
+	// Bank is the rom bank PC is in, 0 outside of 0x4000-0x7FFF
+	Bank int
+	Err  error
+}
+
+func NewEmulationError(pc uint16, opcode byte, bank int, err error) *EmulationError {
+	return &EmulationError{
+		PC:     pc,
+		Opcode: opcode,
+		Bank:   bank,
+		Err:    err,
+	}
+}
+
+func (e *EmulationError) Error() string {
+	return fmt.Sprintf("emulation fault at %02X:%04X (opcode 0x%02X): %v", e.Bank, e.PC, e.Opcode, e.Err)
+}
+
+func (e *EmulationError) Unwrap() error {
+	return e.Err
+}
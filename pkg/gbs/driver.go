@@ -0,0 +1,109 @@
+package gbs
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+)
+
+// The cartridge is the data at its load address, with the driver underneath it. GBS data switches
+// banks by writing to 0x2000 like MBC1, so anything larger than 32KiB is an MBC1 cartridge.
+//
+//	0x0000-0x003F  RST vectors, jumping to the same offset from the load address
+//	0x0040, 0x0050 VBlank and timer interrupts, calling Play
+//	0x0100         entry point, jumping to the driver
+//	0x0150         the driver, setting up the timer and calling Init, then halting forever
+
+const (
+	driverStart = 0x150
+	driverEnd   = 0x200
+	// MBC1 selects banks with 5 bits
+	maxROMSize = 32 * cartridge.ROMBankSize
+)
+
+// Cartridge wraps the data in a cartridge that plays the track, from 1
+func (f *File) Cartridge(track int) (*cartridge.Cartridge, error) {
+	if track < 1 || track > int(f.Songs) {
+		return nil, fmt.Errorf("%w: %d, the file has %d", ErrorInvalidTrack, track, f.Songs)
+	}
+
+	size := int(f.Load) + len(f.Data)
+	if size < 2*cartridge.ROMBankSize {
+		size = 2 * cartridge.ROMBankSize
+	}
+	rom := make([]byte, size)
+	copy(rom[f.Load:], f.Data)
+
+	// https://gbdev.io/pandocs/The_Cartridge_Header.html#0147---cartridge-type
+	rom[0x147] = byte(cartridge.ROM_RAM)
+	if size > 2*cartridge.ROMBankSize {
+		rom[0x147] = byte(cartridge.MB1_RAM)
+	}
+	// 8KiB of ram at 0xA000, which some drivers use as work ram
+	rom[0x149] = 0x02
+
+	for rst := uint16(0); rst < 0x40; rst += 8 {
+		jp(rom[rst:], f.Load+rst)
+	}
+
+	// the interrupt that isn't enabled never fires
+	call(rom[0x40:], f.Play)
+	rom[0x43] = 0xD9 // RETI
+	call(rom[0x50:], f.Play)
+	rom[0x53] = 0xD9 // RETI
+
+	jp(rom[0x100:], driverStart)
+	copy(rom[driverStart:], f.driver(track))
+
+	cart, err := cartridge.FromBytes(rom)
+	if err != nil {
+		return nil, err
+	}
+	rom[0x14D] = cart.CalculateHeaderCheckSum()
+
+	return cart, nil
+}
+
+// driver initializes the track and waits for interrupts
+func (f *File) driver(track int) []byte {
+	ie := byte(0x01) // VBlank
+	if f.UsesTimer() {
+		ie = 0x04 // timer
+	}
+
+	code := []byte{
+		0xF3,       // DI
+		0x31, 0, 0, // LD SP,stack
+		0x3E, 0x0A, // LD A,$0A
+		0xEA, 0x00, 0x00, // LD ($0000),A ; enable cartridge ram
+		0x3E, f.TMA, // LD A,tma
+		0xE0, 0x06, // LDH (TMA),A
+		// double speed (bit 7) is for the CGB
+		0x3E, f.TAC & 0b111, // LD A,tac
+		0xE0, 0x07, // LDH (TAC),A
+		0x3E, byte(track - 1), // LD A,track
+		0xCD, 0, 0, // CALL init
+		0x3E, ie, // LD A,ie
+		0xE0, 0xFF, // LDH (IE),A
+		0xAF,       // XOR A
+		0xE0, 0x0F, // LDH (IF),A
+		0xFB,       // EI
+		0x76,       // HALT
+		0x18, 0xFD, // JR -3
+	}
+	binary.LittleEndian.PutUint16(code[2:], f.Stack)
+	binary.LittleEndian.PutUint16(code[20:], f.Init)
+
+	return code
+}
+
+func jp(rom []byte, address uint16) {
+	rom[0] = 0xC3
+	binary.LittleEndian.PutUint16(rom[1:], address)
+}
+
+func call(rom []byte, address uint16) {
+	rom[0] = 0xCD
+	binary.LittleEndian.PutUint16(rom[1:], address)
+}
@@ -0,0 +1,122 @@
+package gbs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// GBS files are ripped music drivers: the game's sound code and data, with a header saying where
+// to load it and which routines to call. They're played by wrapping them in a cartridge with a
+// small driver that calls init for the track, then play on every VBlank or timer interrupt
+// https://ocremix.org/info/GBS_Format_Specification
+
+// HeaderSize is the size of the header, the data follows it
+const HeaderSize = 0x70
+
+var magic = []byte("GBS")
+
+var (
+	ErrorInvalidMagic = errors.New("not a gbs file")
+	ErrorInvalidTrack = errors.New("invalid track")
+)
+
+// TAC bit 2 plays on the timer interrupt instead of VBlank
+const tacTimerEnabled = 0b100
+
+// Header is the 0x70 byte header at the start of the file
+type Header struct {
+	Magic   [3]byte
+	Version byte
+	// Songs is the number of tracks, FirstSong is the default track from 1
+	Songs     byte
+	FirstSong byte
+	// Load is where the data is loaded, Init is called with the track from 0 in A, then Play is
+	// called on every interrupt
+	Load  uint16
+	Init  uint16
+	Play  uint16
+	Stack uint16
+	// TMA and TAC set up the timer, Play is called on VBlank unless TAC enables the timer
+	TMA       byte
+	TAC       byte
+	Title     [32]byte
+	Author    [32]byte
+	Copyright [32]byte
+}
+
+// UsesTimer checks if Play is called on the timer interrupt, otherwise it's called on VBlank
+func (h *Header) UsesTimer() bool {
+	return h.TAC&tacTimerEnabled != 0
+}
+
+func (h *Header) TitleString() string {
+	return headerString(h.Title)
+}
+
+func (h *Header) AuthorString() string {
+	return headerString(h.Author)
+}
+
+func (h *Header) CopyrightString() string {
+	return headerString(h.Copyright)
+}
+
+// headerString trims the padding from a string in the header
+func headerString(field [32]byte) string {
+	if i := bytes.IndexByte(field[:], 0); i >= 0 {
+		return string(field[:i])
+	}
+	return string(field[:])
+}
+
+type File struct {
+	Header
+	Data []byte
+}
+
+func FromFile(filepath string) (*File, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read gbs file: %w", err)
+	}
+
+	return FromBytes(data)
+}
+
+func FromBytes(data []byte) (*File, error) {
+	if len(data) < HeaderSize || !bytes.Equal(data[:len(magic)], magic) {
+		return nil, ErrorInvalidMagic
+	}
+
+	f := &File{Data: data[HeaderSize:]}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &f.Header); err != nil {
+		return nil, err
+	}
+
+	if f.Version != 1 {
+		return nil, fmt.Errorf("unsupported gbs version: %d", f.Version)
+	}
+	if f.Songs == 0 {
+		return nil, fmt.Errorf("gbs file has no songs")
+	}
+	// the driver lives below the load address
+	if f.Load < driverEnd || f.Load >= 0x8000 {
+		return nil, fmt.Errorf("gbs load address 0x%04X is outside of rom or overlaps the driver", f.Load)
+	}
+	if int(f.Load)+len(f.Data) > maxROMSize {
+		return nil, fmt.Errorf("gbs data is too large: %d bytes", len(f.Data))
+	}
+
+	return f, nil
+}
+
+// DefaultTrack is the track to play when one isn't chosen, from 1
+func (f *File) DefaultTrack() int {
+	if f.FirstSong < 1 || f.FirstSong > f.Songs {
+		return 1
+	}
+	return int(f.FirstSong)
+}
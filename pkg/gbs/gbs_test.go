@@ -0,0 +1,91 @@
+package gbs_test
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/gbs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGBS builds a file whose init stores the track at 0xC000 and whose play counts calls at 0xC001
+func newGBS(tma, tac byte) []byte {
+	header := make([]byte, gbs.HeaderSize)
+	copy(header, "GBS")
+	header[0x03] = 1 // version
+	header[0x04] = 3 // songs
+	header[0x05] = 2 // first song
+	binary.LittleEndian.PutUint16(header[0x06:], 0x400)
+	binary.LittleEndian.PutUint16(header[0x08:], 0x400)
+	binary.LittleEndian.PutUint16(header[0x0A:], 0x404)
+	binary.LittleEndian.PutUint16(header[0x0C:], 0xFFFE)
+	header[0x0E] = tma
+	header[0x0F] = tac
+	copy(header[0x10:], "Test Song")
+	copy(header[0x30:], "Someone")
+
+	return append(header,
+		0xEA, 0x00, 0xC0, // LD ($C000),A
+		0xC9,             // RET
+		0x21, 0x01, 0xC0, // LD HL,$C001
+		0x34, // INC (HL)
+		0xC9, // RET
+	)
+}
+
+func play(t *testing.T, data []byte, track, frames int) *emulator.Emulator {
+	f, err := gbs.FromBytes(data)
+	require.NoError(t, err)
+
+	cart, err := f.Cartridge(track)
+	require.NoError(t, err)
+
+	emu := emulator.New(cart)
+	emu.Pacer.SetUnthrottled(true)
+	require.NoError(t, emu.RunFrames(context.Background(), frames))
+	return emu
+}
+
+func TestHeader(t *testing.T) {
+	f, err := gbs.FromBytes(newGBS(0, 0))
+	require.NoError(t, err)
+
+	assert.Equal(t, byte(3), f.Songs)
+	assert.Equal(t, 2, f.DefaultTrack())
+	assert.Equal(t, uint16(0x404), f.Play)
+	assert.Equal(t, "Test Song", f.TitleString())
+	assert.Equal(t, "Someone", f.AuthorString())
+	assert.Equal(t, "", f.CopyrightString())
+	assert.False(t, f.UsesTimer())
+	assert.Len(t, f.Data, 9)
+
+	_, err = f.Cartridge(4)
+	assert.ErrorIs(t, err, gbs.ErrorInvalidTrack)
+
+	_, err = gbs.FromBytes([]byte("GBX"))
+	assert.ErrorIs(t, err, gbs.ErrorInvalidMagic)
+
+	data := newGBS(0, 0)
+	binary.LittleEndian.PutUint16(data[0x06:], 0x100)
+	_, err = gbs.FromBytes(data)
+	assert.Error(t, err)
+}
+
+func TestVBlank(t *testing.T) {
+	emu := play(t, newGBS(0, 0), 3, 60)
+
+	assert.Equal(t, byte(2), emu.MMU.Read8(0xC000))
+	// play is called once a frame
+	assert.InDelta(t, 60, emu.MMU.Read8(0xC001), 1)
+}
+
+func TestTimer(t *testing.T) {
+	// 4096 Hz, overflowing every 256 ticks is 16 Hz
+	emu := play(t, newGBS(0x00, 0x04), 1, 60)
+
+	assert.Equal(t, byte(0), emu.MMU.Read8(0xC000))
+	assert.InDelta(t, 16, emu.MMU.Read8(0xC001), 1)
+}
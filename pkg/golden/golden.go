@@ -0,0 +1,225 @@
+package golden
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+)
+
+// Golden frame tests run a rom for a number of frames and compare the screen to a checked in PNG.
+// When it doesn't match, an image highlighting the differences is written next to the golden.
+// Run the tests with -update to write the current frames as the new goldens. The flag is only
+// defined in test binaries that import this package, so list those packages:
+//
+//	go test ./pkg/golden ./pkg/testrom -update
+//
+// Only goldens in a testdata directory are written. Anything else is a reference image from
+// elsewhere (ie: dmg-acid2's), it's always compared against and never overwritten.
+
+var update = flag.Bool("update", false, "write golden frames instead of comparing against them")
+
+// DefaultFrames is how long a rom runs if Options.Frames isn't set, ten seconds
+const DefaultFrames = 600
+
+// Input holds down buttons from a frame until the next input
+type Input struct {
+	Frame   uint64
+	Buttons joypad.Button
+}
+
+type Options struct {
+	// Frames is the number of frames to run before comparing
+	Frames int
+	// Inputs are the buttons to press, sorted by frame
+	Inputs []Input
+	// Palette the frame is rendered with, the default is ppu.PaletteGray
+	Palette *ppu.Palette
+}
+
+// Run the cartridge for the number of frames, pressing the inputs, and render the last frame. It
+// stops early if ctx is done
+func Run(ctx context.Context, cart *cartridge.Cartridge, opts Options) (*image.RGBA, error) {
+	if opts.Frames == 0 {
+		opts.Frames = DefaultFrames
+	}
+	palette := ppu.PaletteGray
+	if opts.Palette != nil {
+		palette = *opts.Palette
+	}
+
+	emu := emulator.New(cart)
+	emu.FrameHook = &script{inputs: opts.Inputs}
+
+	if err := emu.RunFrames(ctx, opts.Frames); err != nil {
+		return nil, err
+	}
+
+	return emu.Framebuffer().Image(palette, 1), nil
+}
+
+// Require runs the rom at path and compares the last frame to the golden PNG, failing the test on
+// a mismatch. Tests are skipped if the rom doesn't exist, test roms aren't checked in.
+func Require(t testing.TB, romPath, goldenPath string, opts Options) {
+	t.Helper()
+
+	cart, err := cartridge.FromFile(romPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skipf("test rom not found: %s", romPath)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	RequireCartridge(t, cart, goldenPath, opts)
+}
+
+// RequireCartridge is Require for a cartridge that's already loaded, ie: homebrew built in a test
+func RequireCartridge(t testing.TB, cart *cartridge.Cartridge, goldenPath string, opts Options) {
+	t.Helper()
+
+	got, err := Run(context.Background(), cart, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update && generated(goldenPath) {
+		if err := writePNG(goldenPath, got); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("updated golden frame %s", goldenPath)
+		return
+	}
+
+	want, err := readPNG(goldenPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("golden frame not found: %s, run with -update to create it", goldenPath)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, mismatched := Diff(want, got)
+	if mismatched == 0 {
+		return
+	}
+
+	diffPath := strings.TrimSuffix(goldenPath, ".png") + ".diff.png"
+	if err := writePNG(diffPath, diff); err != nil {
+		t.Fatal(err)
+	}
+	t.Fatalf("frame doesn't match %s: %d pixels differ, see %s", goldenPath, mismatched, diffPath)
+}
+
+// generated checks if the golden is one of ours in a testdata directory, rather than a reference
+func generated(goldenPath string) bool {
+	for dir := filepath.Dir(goldenPath); ; dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == "testdata" {
+			return true
+		}
+		if dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// mismatched pixels are red, matching ones are a faded copy of the golden
+var diffColor = color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+
+// Diff compares the images, returning an image with the differences highlighted and how many
+// pixels differ. Images of different sizes differ everywhere the other is out of bounds
+func Diff(want, got image.Image) (*image.RGBA, int) {
+	bounds := want.Bounds().Union(got.Bounds())
+	diff := image.NewRGBA(bounds)
+	mismatched := 0
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pt := image.Pt(x, y)
+			w := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+			g := color.RGBAModel.Convert(got.At(x, y)).(color.RGBA)
+
+			if pt.In(want.Bounds()) && pt.In(got.Bounds()) && w == g {
+				diff.SetRGBA(x, y, fade(w))
+				continue
+			}
+
+			diff.SetRGBA(x, y, diffColor)
+			mismatched++
+		}
+	}
+
+	return diff, mismatched
+}
+
+// fade lightens a color so the red differences stand out
+func fade(c color.RGBA) color.RGBA {
+	return color.RGBA{
+		R: 0xC0 + c.R/4,
+		G: 0xC0 + c.G/4,
+		B: 0xC0 + c.B/4,
+		A: 0xFF,
+	}
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %s: %w", path, err)
+	}
+
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+
+	return f.Close()
+}
+
+// script is a frame hook that presses the inputs
+type script struct {
+	inputs []Input
+	frame  uint64
+}
+
+func (s *script) BeforeFrame(emu *emulator.Emulator) error {
+	for len(s.inputs) > 0 && s.inputs[0].Frame <= s.frame {
+		emu.Joypad.SetPressed(s.inputs[0].Buttons)
+		s.inputs = s.inputs[1:]
+	}
+
+	s.frame++
+	return nil
+}
+
+func (s *script) AfterFrame(emu *emulator.Emulator) error {
+	return nil
+}
@@ -0,0 +1,130 @@
+package golden_test
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/robherley/go-gameboy/internal/testutil"
+	"github.com/robherley/go-gameboy/pkg/golden"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stripes fills tile 0, which covers the screen, with vertical stripes of every color. Then it
+// waits for A and inverts the palette
+var stripes = []byte{
+	0x21, 0x00, 0x80, // LD HL,$8000
+	0x06, 0x08, // LD B,8
+	0x36, 0x0F, // loop: LD (HL),$0F
+	0x23,       // INC HL
+	0x36, 0x33, // LD (HL),$33
+	0x23,       // INC HL
+	0x05,       // DEC B
+	0x20, 0xF7, // JR NZ,loop
+	0x3E, 0x10, // LD A,$10
+	0xE0, 0x00, // LDH ($00),A
+	0xF0, 0x00, // poll: LDH A,($00)
+	0xCB, 0x47, // BIT 0,A
+	0x20, 0xFA, // JR NZ,poll
+	0x3E, 0x1B, // LD A,$1B
+	0xE0, 0x47, // LDH ($47),A
+	0x18, 0xFE, // JR -2
+}
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		golden string
+		opts   golden.Options
+	}{
+		{"stripes", "testdata/stripes.png", golden.Options{Frames: 10}},
+		{"pressed", "testdata/stripes_inverted.png", golden.Options{
+			Frames: 10,
+			Inputs: []golden.Input{{Frame: 3, Buttons: joypad.A}, {Frame: 5}},
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			golden.RequireCartridge(t, testutil.Cartridge(t, stripes...), tc.golden, tc.opts)
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	want := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	got := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	got.SetRGBA(1, 2, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF})
+
+	diff, mismatched := golden.Diff(want, got)
+	assert.Equal(t, 1, mismatched)
+	assert.Equal(t, color.RGBA{0xFF, 0x00, 0x00, 0xFF}, diff.RGBAAt(1, 2))
+	assert.NotEqual(t, diff.RGBAAt(1, 2), diff.RGBAAt(0, 0))
+
+	_, mismatched = golden.Diff(want, image.NewRGBA(image.Rect(0, 0, 4, 5)))
+	assert.Equal(t, 4, mismatched)
+}
+
+// fatalRecorder records a failure instead of failing the test
+type fatalRecorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *fatalRecorder) Fatal(args ...any) {
+	r.failed = true
+	runtime.Goexit()
+}
+
+func (r *fatalRecorder) Fatalf(format string, args ...any) {
+	r.failed = true
+	runtime.Goexit()
+}
+
+// requireFails runs f on its own goroutine, like a test, so it can stop at the first Fatal
+func requireFails(t *testing.T, f func(t testing.TB)) {
+	r := &fatalRecorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(r)
+	}()
+	<-done
+
+	assert.True(t, r.failed)
+}
+
+func TestUpdate(t *testing.T) {
+	require.NoError(t, flag.Set("update", "true"))
+	defer flag.Set("update", "false")
+
+	dir := t.TempDir()
+	pressed := golden.Options{Frames: 10, Inputs: []golden.Input{{Frame: 3, Buttons: joypad.A}, {Frame: 5}}}
+
+	// goldens in testdata are written
+	generated := filepath.Join(dir, "testdata", "stripes.png")
+	require.NoError(t, os.Mkdir(filepath.Dir(generated), 0o755))
+	golden.RequireCartridge(t, testutil.Cartridge(t, stripes...), generated, golden.Options{Frames: 10})
+	assert.FileExists(t, generated)
+
+	// references aren't, they're still compared
+	reference := filepath.Join(dir, "reference.png")
+	data, err := os.ReadFile(generated)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(reference, data, 0o644))
+
+	cart := testutil.Cartridge(t, stripes...)
+	requireFails(t, func(t testing.TB) {
+		golden.RequireCartridge(t, cart, reference, pressed)
+	})
+
+	unchanged, err := os.ReadFile(reference)
+	require.NoError(t, err)
+	assert.Equal(t, data, unchanged)
+}
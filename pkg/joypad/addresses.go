@@ -0,0 +1,6 @@
+package joypad
+
+const (
+	// Joypad input
+	P1_ADDRESS uint16 = 0xFF00
+)
@@ -0,0 +1,141 @@
+package joypad
+
+import (
+	"fmt"
+	"strings"
+
+	errs "github.com/robherley/go-gameboy/pkg/errors"
+)
+
+// Button is a set of buttons, the lower nibble is the d-pad and the upper nibble is the rest
+type Button byte
+
+const (
+	Right Button = 1 << iota
+	Left
+	Up
+	Down
+	A
+	B
+	Select
+	Start
+)
+
+var names = []string{"Right", "Left", "Up", "Down", "A", "B", "Select", "Start"}
+
+func (b Button) String() string {
+	pressed := []string{}
+	for i, name := range names {
+		if b&(1<<i) != 0 {
+			pressed = append(pressed, name)
+		}
+	}
+
+	return strings.Join(pressed, "+")
+}
+
+// ParseButton parses a button by name, ignoring case
+func ParseButton(name string) (Button, error) {
+	for i, n := range names {
+		if strings.EqualFold(name, n) {
+			return 1 << i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown button %q, must be one of %s", name, strings.Join(names, ", "))
+}
+
+const (
+	// P14, when cleared the d-pad can be read
+	selectDirections = 1 << 4
+	// P15, when cleared the buttons can be read
+	selectButtons = 1 << 5
+)
+
+// https://gbdev.io/pandocs/Joypad_Input.html
+type Joypad struct {
+	// buttons that are held down
+	pressed Button
+	// P14 and P15, which half of the buttons are read
+	selection byte
+	// Callback for interrupt
+	OnInterrupt func()
+}
+
+func New(interruptFunc func()) *Joypad {
+	return &Joypad{
+		selection:   selectDirections | selectButtons,
+		OnInterrupt: interruptFunc,
+	}
+}
+
+// Pressed returns the buttons that are held down
+func (j *Joypad) Pressed() Button {
+	return j.pressed
+}
+
+// SetPressed sets all of the buttons that are held down. The interrupt is requested when a
+// selected line goes low, ie: a button is pressed
+func (j *Joypad) SetPressed(pressed Button) {
+	prev := j.lines()
+	j.pressed = pressed
+
+	if prev&^j.lines() != 0 && j.OnInterrupt != nil {
+		j.OnInterrupt()
+	}
+}
+
+// Press holds down the buttons
+func (j *Joypad) Press(b Button) {
+	j.SetPressed(j.pressed | b)
+}
+
+// Release lets go of the buttons
+func (j *Joypad) Release(b Button) {
+	j.SetPressed(j.pressed &^ b)
+}
+
+// lines returns P10-P13, which are 0 when a selected button is pressed
+func (j *Joypad) lines() byte {
+	lines := byte(0)
+	if j.selection&selectDirections == 0 {
+		lines |= byte(j.pressed) & 0x0F
+	}
+	if j.selection&selectButtons == 0 {
+		lines |= byte(j.pressed) >> 4
+	}
+
+	return ^lines & 0x0F
+}
+
+func (j *Joypad) Read(address uint16) byte {
+	if address != P1_ADDRESS {
+		panic(errs.NewReadError(address, "joypad"))
+	}
+
+	// upper bits are unused and read as 1
+	return 0xC0 | j.selection | j.lines()
+}
+
+func (j *Joypad) Write(address uint16, data byte) {
+	if address != P1_ADDRESS {
+		panic(errs.NewWriteError(address, "joypad"))
+	}
+
+	j.selection = data & (selectDirections | selectButtons)
+}
+
+// MarshalBinary encodes the selection for save states, the buttons are input and aren't saved
+func (j *Joypad) MarshalBinary() ([]byte, error) {
+	return []byte{j.selection}, nil
+}
+
+// UnmarshalBinary restores the joypad from MarshalBinary
+func (j *Joypad) UnmarshalBinary(data []byte) error {
+	if len(data) != 1 {
+		return errs.NewInvalidStateError("joypad", len(data))
+	}
+
+	j.selection = data[0] & (selectDirections | selectButtons)
+	return nil
+}
@@ -0,0 +1,65 @@
+package joypad_test
+
+import (
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	cases := []struct {
+		name      string
+		pressed   joypad.Button
+		selection byte
+		expected  byte
+	}{
+		{"nothing selected", joypad.A | joypad.Up, 0x30, 0xFF},
+		{"directions", joypad.A | joypad.Up, 0x20, 0xEB},
+		{"buttons", joypad.A | joypad.Up, 0x10, 0xDE},
+		{"both", joypad.A | joypad.Up, 0x00, 0xCA},
+		{"nothing pressed", 0, 0x00, 0xCF},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			j := joypad.New(nil)
+			j.SetPressed(tc.pressed)
+			j.Write(joypad.P1_ADDRESS, tc.selection)
+			assert.Equal(t, tc.expected, j.Read(joypad.P1_ADDRESS))
+		})
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	interrupts := 0
+	j := joypad.New(func() { interrupts++ })
+
+	// not selected
+	j.Press(joypad.Start)
+	assert.Equal(t, 0, interrupts)
+
+	j.Write(joypad.P1_ADDRESS, 0x10)
+	j.Press(joypad.A)
+	assert.Equal(t, 1, interrupts)
+
+	// released and already pressed buttons don't request it
+	j.Release(joypad.A)
+	j.Press(joypad.Start)
+	assert.Equal(t, 1, interrupts)
+}
+
+func TestButtonString(t *testing.T) {
+	assert.Equal(t, "Up+A+Start", (joypad.Up | joypad.A | joypad.Start).String())
+	assert.Equal(t, "", joypad.Button(0).String())
+}
+
+func TestParseButton(t *testing.T) {
+	b, err := joypad.ParseButton("select")
+	require.NoError(t, err)
+	assert.Equal(t, joypad.Select, b)
+
+	_, err = joypad.ParseButton("Turbo")
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package mmu
+
+import (
+	"github.com/robherley/go-gameboy/pkg/ppu"
+)
+
+// OAM DMA copies 0xA0 bytes from XX00-XX9F to OAM, one byte per M-cycle. While it runs the cpu
+// can't access OAM.
+// https://gbdev.io/pandocs/OAM_DMA_Transfer.html
+type dma struct {
+	// source is the high byte of the address copied from, and what FF46 reads back as
+	source byte
+	// index of the next byte to copy
+	index  byte
+	active bool
+}
+
+func (d *dma) Read(address uint16) byte {
+	return d.source
+}
+
+func (d *dma) Write(address uint16, data byte) {
+	d.source = data
+	d.index = 0
+	d.active = true
+}
+
+// step copies the next byte of a transfer, if there is one
+func (d *dma) step(mmu *MMU) {
+	if !d.active {
+		return
+	}
+
+	src := uint16(d.source)<<8 | uint16(d.index)
+	// sources past work ram are mirrored like echo ram
+	if src >= 0xE000 {
+		src -= 0x2000
+	}
+
+	mmu.ppu.Write(ppu.OAM_OFFSET+uint16(d.index), mmu.Read8(src))
+
+	d.index++
+	if d.index == ppu.OAM_SIZE {
+		d.active = false
+	}
+}
+
+// blocked is OAM while a transfer is running, reads are 0xFF and writes are ignored
+type blocked struct{}
+
+func (blocked) Read(address uint16) byte {
+	return 0xFF
+}
+
+func (blocked) Write(address uint16, data byte) {}
@@ -2,6 +2,7 @@ package mmu
 
 import (
 	errs "github.com/robherley/go-gameboy/pkg/errors"
+	"github.com/robherley/go-gameboy/pkg/ppu"
 )
 
 // Range is a inclusive start/end range defined in memory
@@ -71,24 +72,36 @@ type readerWriter interface {
 }
 
 func (mmu *MMU) readerWriterFor(addr uint16) readerWriter {
+	// unimplemented hardware is ignored, even in strict mode
 	strict := false
 
 	if ROMRange.Contains(addr) {
 		return mmu.cartridge
 	} else if CharMapRange.Contains(addr) {
-		return newNoop(strict)
+		return mmu.ppu
 	} else if CartRAMRange.Contains(addr) {
 		return mmu.cartridge
 	} else if WRAMRange.Contains(addr) {
 		return mmu.wram
 	} else if RESERVED_EchoRamRange.Contains(addr) {
-		panic(errs.NewAccessError(addr, "reserved echo memory"))
+		if mmu.strict {
+			panic(errs.NewAccessError(addr, "reserved echo memory"))
+		}
+		return mmu.echo
 	} else if OAMRange.Contains(addr) {
-		return newNoop(strict)
+		if mmu.dma.active {
+			return blocked{}
+		}
+		return mmu.ppu
 	} else if RESERVED_UnusableRange.Contains(addr) {
-		panic(errs.NewAccessError(addr, "reserved unused memory"))
+		if mmu.strict {
+			panic(errs.NewAccessError(addr, "reserved unused memory"))
+		}
+		// reads 0x00 on DMG, other models differ and it depends on the ppu mode
+		// https://gbdev.io/pandocs/Memory_Map.html#fea0feff-range
+		return mmu.noop
 	} else if JobpadInputRange.Contains(addr) {
-		return newNoop(strict)
+		return mmu.joypad
 	} else if SerialTransferRange.Contains(addr) {
 		return mmu.serial
 	} else if TimerDividerRange.Contains(addr) {
@@ -96,21 +109,24 @@ func (mmu *MMU) readerWriterFor(addr uint16) readerWriter {
 	} else if InterruptFlagRange.Contains(addr) {
 		return mmu.interrupt
 	} else if AudioRange.Contains(addr) {
-		return newNoop(strict)
+		return mmu.apu
 	} else if LCDRange.Contains(addr) {
-		return newNoop(strict)
+		if addr == ppu.DMA_ADDRESS {
+			return mmu.dma
+		}
+		return mmu.ppu
 	} else if ColorSpeedSwitchRange.Contains(addr) {
-		return newNoop(strict)
+		return mmu.noopFor(strict)
 	} else if VRAMBankSelectRange.Contains(addr) {
-		return newNoop(strict)
+		return mmu.noopFor(strict)
 	} else if DisableBootRomRange.Contains(addr) {
-		return newNoop(strict)
+		return mmu.noopFor(strict)
 	} else if VRAMDMARange.Contains(addr) {
-		return newNoop(strict)
+		return mmu.noopFor(strict)
 	} else if BgObjPaletteRange.Contains(addr) {
-		return newNoop(strict)
+		return mmu.noopFor(strict)
 	} else if WRAMBankSelectRange.Contains(addr) {
-		return newNoop(strict)
+		return mmu.noopFor(strict)
 	} else if HRAMRange.Contains(addr) {
 		return mmu.hram
 	} else if InterruptEnableRange.Contains(addr) {
@@ -119,3 +135,11 @@ func (mmu *MMU) readerWriterFor(addr uint16) readerWriter {
 		panic(errs.NewAccessError(addr, "mmu"))
 	}
 }
+
+// noopFor returns the shared noop, which panics on access when strict
+func (mmu *MMU) noopFor(strict bool) *noop {
+	if strict {
+		return mmu.strictNoop
+	}
+	return mmu.noop
+}
@@ -1,12 +1,15 @@
 package mmu
 
 import (
-	"fmt"
+	"io"
 
 	"github.com/robherley/go-gameboy/internal/bits"
+	"github.com/robherley/go-gameboy/pkg/apu"
 	"github.com/robherley/go-gameboy/pkg/cartridge"
 	errs "github.com/robherley/go-gameboy/pkg/errors"
 	"github.com/robherley/go-gameboy/pkg/interrupt"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/ppu"
 	"github.com/robherley/go-gameboy/pkg/timer"
 )
 
@@ -14,26 +17,62 @@ type MMU struct {
 	cartridge *cartridge.Cartridge
 	hram      *ram
 	wram      *ram
+	echo      *echo
 	serial    *serial
+	dma       *dma
 	interrupt *interrupt.Interrupt
-	lcd       *lcd
+	ppu       *ppu.PPU
+	apu       *apu.APU
 	timer     *timer.Timer
+	joypad    *joypad.Joypad
+	// unmapped io shares these instead of allocating on every access
+	noop       *noop
+	strictNoop *noop
+	// strict panics when accessing echo ram or the unusable region
+	strict bool
 }
 
 func New(
 	cart *cartridge.Cartridge,
 	inter *interrupt.Interrupt,
 	time *timer.Timer,
+	pad *joypad.Joypad,
+	video *ppu.PPU,
+	sound *apu.APU,
 ) *MMU {
+	// the frame sequencer is clocked by DIV
+	time.OnFrameSequencer = sound.ClockFrameSequencer
+
+	wram := newWRAM()
 	return &MMU{
 		cartridge: cart,
 		hram:      newHRAM(),
-		wram:      newWRAM(),
-		serial:    newSerial(),
-		interrupt: inter,
-		lcd:       newLCD(),
-		timer:     time,
+		wram:      wram,
+		echo:      &echo{wram},
+		serial: newSerial(func() {
+			inter.Flag |= byte(interrupt.SERIAL)
+		}),
+		dma:        &dma{},
+		interrupt:  inter,
+		ppu:        video,
+		apu:        sound,
+		timer:      time,
+		joypad:     pad,
+		noop:       newNoop(false),
+		strictNoop: newNoop(true),
+	}
+}
+
+// Tick advances the hardware on the bus by one M-cycle
+func (mmu *MMU) Tick() {
+	mmu.dma.step(mmu)
+
+	for i := 0; i < 4; i++ {
+		mmu.timer.Tick()
+		mmu.ppu.Tick()
 	}
+	mmu.apu.Tick()
+	mmu.cartridge.Tick()
 }
 
 func (mmu *MMU) Read8(address uint16) byte {
@@ -66,15 +105,62 @@ func (mmu *MMU) Write16(address uint16, value uint16) {
 	mmu.Write8(address+1, bits.Hi(value))
 }
 
+// SetStrict makes accessing echo ram and the unusable region panic,
+// instead of mirroring work ram and reading 0x00 like the hardware
+func (mmu *MMU) SetStrict(strict bool) {
+	mmu.strict = strict
+}
+
 func (mmu *MMU) DebugMem() {
 	// fmt.Printf("%X\n", mmu.Read8(0xd800))
 }
 
-func (mmu *MMU) DebugSerial() {
-	// if first and last bits are set, read in debug data
-	if mmu.serial.Read(SC_SERIAL_CONTROL) == 0x81 {
-		ch := mmu.serial.Read(SB_SERIAL_TRANSFER)
-		fmt.Printf("%c", ch)
-		mmu.serial.Write(SC_SERIAL_CONTROL, 0)
+// SetSerialOutput sets where bytes sent over the link cable are written, nil discards them
+func (mmu *MMU) SetSerialOutput(w io.Writer) {
+	mmu.serial.output = w
+}
+
+// MarshalBinary encodes the memory, serial registers and dma transfer owned by the MMU for save states
+func (mmu *MMU) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, mmu.stateSize())
+	for _, r := range mmu.rams() {
+		data = append(data, r.memory...)
+	}
+
+	active := byte(0)
+	if mmu.dma.active {
+		active = 1
+	}
+
+	return append(data, mmu.serial.transfer, mmu.serial.control, mmu.dma.source, mmu.dma.index, active), nil
+}
+
+// UnmarshalBinary restores the memory from MarshalBinary
+func (mmu *MMU) UnmarshalBinary(data []byte) error {
+	if len(data) != mmu.stateSize() {
+		return errs.NewInvalidStateError("mmu", len(data))
+	}
+
+	for _, r := range mmu.rams() {
+		data = data[copy(r.memory, data):]
+	}
+
+	mmu.serial.transfer, mmu.serial.control = data[0], data[1]
+	mmu.dma.source, mmu.dma.index, mmu.dma.active = data[2], data[3], data[4] != 0
+	return nil
+}
+
+// rams returns the memory regions in the order they're saved
+func (mmu *MMU) rams() []*ram {
+	return []*ram{mmu.wram, mmu.hram}
+}
+
+func (mmu *MMU) stateSize() int {
+	// serial transfer and control, dma source, index and active
+	size := 5
+	for _, r := range mmu.rams() {
+		size += len(r.memory)
 	}
+
+	return size
 }
@@ -1,6 +1,6 @@
 package mmu
 
-import "fmt"
+import errs "github.com/robherley/go-gameboy/pkg/errors"
 
 type noop struct {
 	strict bool
@@ -12,15 +12,13 @@ func newNoop(strict bool) *noop {
 
 func (n *noop) Read(address uint16) byte {
 	if n.strict {
-		fmt.Printf("NOOP[%04X] read", address)
-		panic("noop: strict mode")
+		panic(errs.NewReadError(address, "unmapped io"))
 	}
 	return 0x0
 }
 
 func (n *noop) Write(address uint16, data byte) {
 	if n.strict {
-		fmt.Printf("NOOP[%04X] write: %02X\n", address, data)
-		panic("noop: strict mode")
+		panic(errs.NewWriteError(address, "unmapped io"))
 	}
 }
@@ -52,3 +52,17 @@ func (r *ram) Read(address uint16) byte {
 func (r *ram) Write(address uint16, data byte) {
 	r.memory[r.translateAddress(address)] = data
 }
+
+// echo mirrors 0xC000-0xDDFF at 0xE000-0xFDFF
+// https://gbdev.io/pandocs/Memory_Map.html#echo-ram
+type echo struct {
+	wram *ram
+}
+
+func (e *echo) Read(address uint16) byte {
+	return e.wram.Read(address - 0x2000)
+}
+
+func (e *echo) Write(address uint16, data byte) {
+	e.wram.Write(address-0x2000, data)
+}
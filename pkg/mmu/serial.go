@@ -1,19 +1,32 @@
 package mmu
 
-import errs "github.com/robherley/go-gameboy/pkg/errors"
+import (
+	"io"
+
+	errs "github.com/robherley/go-gameboy/pkg/errors"
+)
 
 const (
 	SB_SERIAL_TRANSFER = 0xFF01
 	SC_SERIAL_CONTROL  = 0xFF02
 )
 
+// https://gbdev.io/pandocs/Serial_Data_Transfer_(Link_Cable).html
 type serial struct {
 	transfer byte
 	control  byte
+	// output receives every byte shifted out, test roms report their results this way
+	output io.Writer
+	// callback for interrupt
+	onInterrupt func()
 }
 
-func newSerial() *serial {
-	return &serial{0x0, 0x0}
+func newSerial(interruptFunc func()) *serial {
+	return &serial{
+		transfer:    0x0,
+		control:     0x0,
+		onInterrupt: interruptFunc,
+	}
 }
 
 func (s *serial) Read(address uint16) byte {
@@ -21,7 +34,8 @@ func (s *serial) Read(address uint16) byte {
 	case SB_SERIAL_TRANSFER:
 		return s.transfer
 	case SC_SERIAL_CONTROL:
-		return s.control
+		// unused bits read as 1
+		return s.control | 0x7E
 	default:
 		panic(errs.NewReadError(address, "serial"))
 	}
@@ -33,7 +47,26 @@ func (s *serial) Write(address uint16, data byte) {
 		s.transfer = data
 	case SC_SERIAL_CONTROL:
 		s.control = data
+		// transfer requested (bit 7) using the internal clock (bit 0)
+		if data&0x81 == 0x81 {
+			s.shift()
+		}
 	default:
 		panic(errs.NewWriteError(address, "serial"))
 	}
 }
+
+// shift completes a transfer immediately. There's never anything on the other end of
+// the link cable, so 0xFF is shifted in.
+func (s *serial) shift() {
+	if s.output != nil {
+		s.output.Write([]byte{s.transfer})
+	}
+
+	s.transfer = 0xFF
+	s.control &^= 0x80
+
+	if s.onInterrupt != nil {
+		s.onInterrupt()
+	}
+}
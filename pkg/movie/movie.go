@@ -0,0 +1,171 @@
+package movie
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+)
+
+// Movies record the joypad for every frame from power on, so a session can be played back exactly.
+// Every so often a hash of the machine's state is recorded, playback checks it to catch a desync
+// on the frame it happens.
+//
+//	"GBMV" | version (uint16) | header | frames (uint32) | input per frame... | checkpoints (uint32) | checkpoint...
+
+const (
+	magic   = "GBMV"
+	Version = 1
+
+	// readBlockSize is how many frames of input are read at a time
+	readBlockSize = 4096
+
+	// DefaultCheckpointInterval is how often the state is hashed, once a second
+	DefaultCheckpointInterval = 60
+
+	// Model is the only model go-gameboy emulates
+	Model = "DMG "
+)
+
+var (
+	ErrorInvalidMovie = errors.New("invalid movie")
+	// ErrorMismatch is returned when the movie was recorded with a different rom or save
+	ErrorMismatch = errors.New("movie does not match")
+	// ErrorEnded is returned by the player after the last frame
+	ErrorEnded = errors.New("movie ended")
+)
+
+// Header is the configuration the movie was recorded with, playback needs to start from the same place
+type Header struct {
+	// ROMHash is the SHA-256 of the rom
+	ROMHash [32]byte
+	Model   [4]byte
+	// RTCSeed is the time on the cartridge clock at power on, in seconds. Playback starts the clock
+	// from it, it's zero for cartridges without one
+	RTCSeed int64
+	// SaveRAMHash is the SHA-256 of the cartridge ram at power on
+	SaveRAMHash [32]byte
+	// CheckpointInterval is the number of frames between checkpoints
+	CheckpointInterval uint32
+}
+
+// Checkpoint is a hash of the state after a frame
+type Checkpoint struct {
+	Frame uint32
+	Hash  uint64
+}
+
+type Movie struct {
+	Header
+	// Inputs are the buttons held down for each frame
+	Inputs      []joypad.Button
+	Checkpoints []Checkpoint
+}
+
+// HeaderFor returns the header for the emulator's current configuration
+func HeaderFor(emu *emulator.Emulator) Header {
+	h := Header{
+		ROMHash:            sha256.Sum256(emu.Cartridge.Data),
+		RTCSeed:            emu.Cartridge.Clock(),
+		SaveRAMHash:        sha256.Sum256(emu.Cartridge.RAM),
+		CheckpointInterval: DefaultCheckpointInterval,
+	}
+	copy(h.Model[:], Model)
+
+	return h
+}
+
+// Hash returns the hash of the emulator's state used in checkpoints
+func Hash(emu *emulator.Emulator) (uint64, error) {
+	h := fnv.New64a()
+	if err := emu.SaveState(h); err != nil {
+		return 0, err
+	}
+
+	return h.Sum64(), nil
+}
+
+func (m *Movie) WriteTo(w io.Writer) (int64, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(magic)
+
+	values := []any{
+		uint16(Version),
+		m.Header,
+		uint32(len(m.Inputs)),
+		m.Inputs,
+		uint32(len(m.Checkpoints)),
+		m.Checkpoints,
+	}
+	for _, v := range values {
+		binary.Write(buf, binary.LittleEndian, v)
+	}
+
+	return buf.WriteTo(w)
+}
+
+// Read a movie written with WriteTo
+func Read(r io.Reader) (*Movie, error) {
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, header); err != nil || string(header) != magic {
+		return nil, fmt.Errorf("%w: not a movie", ErrorInvalidMovie)
+	}
+
+	var version uint16
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidMovie, err)
+	}
+	if version > Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrorInvalidMovie, version)
+	}
+
+	m := &Movie{}
+	if err := binary.Read(r, binary.LittleEndian, &m.Header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidMovie, err)
+	}
+
+	var frames uint32
+	if err := binary.Read(r, binary.LittleEndian, &frames); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidMovie, err)
+	}
+
+	// the count can't be trusted, the inputs are read a block at a time so a corrupt movie fails
+	// when it runs out instead of allocating for billions of frames
+	m.Inputs = []joypad.Button{}
+	block := make([]byte, readBlockSize)
+	for remaining := int(frames); remaining > 0; {
+		n := len(block)
+		if remaining < n {
+			n = remaining
+		}
+
+		if _, err := io.ReadFull(r, block[:n]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrorInvalidMovie, err)
+		}
+		for _, b := range block[:n] {
+			m.Inputs = append(m.Inputs, joypad.Button(b))
+		}
+		remaining -= n
+	}
+
+	var checkpoints uint32
+	if err := binary.Read(r, binary.LittleEndian, &checkpoints); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidMovie, err)
+	}
+	if checkpoints > frames {
+		return nil, fmt.Errorf("%w: more checkpoints than frames", ErrorInvalidMovie)
+	}
+
+	m.Checkpoints = make([]Checkpoint, checkpoints)
+	if err := binary.Read(r, binary.LittleEndian, m.Checkpoints); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidMovie, err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,154 @@
+package movie_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/robherley/go-gameboy/internal/testutil"
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/movie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sums the direction keys into C forever, so the state depends on the input
+var program = []byte{
+	0x3E, 0x20, // LD A,$20
+	0xE0, 0x00, // LDH ($00),A
+	0xF0, 0x00, // LDH A,($00)
+	0x81,       // ADD A,C
+	0x4F,       // LD C,A
+	0x18, 0xFA, // JR -6
+}
+
+// record a movie pressing a different direction every 10 frames
+func record(t *testing.T, frames int) *movie.Movie {
+	emu := testutil.Emulator(t, program...)
+	rec, err := movie.Record(emu)
+	require.NoError(t, err)
+
+	directions := []joypad.Button{joypad.Right, joypad.Left, joypad.Up, joypad.Down}
+	for i := 0; i < frames; i++ {
+		emu.Joypad.SetPressed(directions[(i/10)%len(directions)])
+		require.NoError(t, emu.RunFrames(context.Background(), 1))
+	}
+
+	return rec.Movie()
+}
+
+func TestPlayback(t *testing.T) {
+	m := record(t, 200)
+	assert.Len(t, m.Inputs, 200)
+	assert.Len(t, m.Checkpoints, 200/movie.DefaultCheckpointInterval)
+
+	buf := &bytes.Buffer{}
+	_, err := m.WriteTo(buf)
+	require.NoError(t, err)
+
+	read, err := movie.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, m, read)
+
+	emu := testutil.Emulator(t, program...)
+	player, err := movie.Play(emu, read, true)
+	require.NoError(t, err)
+
+	err = emu.RunFrames(context.Background(), 300)
+	assert.ErrorIs(t, err, movie.ErrorEnded)
+	assert.True(t, player.Done())
+	assert.Equal(t, uint64(200), emu.Frame())
+}
+
+func TestPlaybackDesync(t *testing.T) {
+	m := record(t, 200)
+	m.Inputs[70] = joypad.Up
+
+	emu := testutil.Emulator(t, program...)
+	_, err := movie.Play(emu, m, true)
+	require.NoError(t, err)
+
+	err = emu.RunFrames(context.Background(), 200)
+	var desync *movie.DesyncError
+	require.True(t, errors.As(err, &desync), "got %v", err)
+	// detected at the next checkpoint
+	assert.Equal(t, uint32(120), desync.Frame)
+}
+
+func TestPlaybackMismatch(t *testing.T) {
+	m := record(t, 10)
+
+	emu := testutil.Emulator(t, 0x18, 0xFE)
+	_, err := movie.Play(emu, m, true)
+	assert.ErrorIs(t, err, movie.ErrorMismatch)
+
+	emu = testutil.Emulator(t, program...)
+	require.NoError(t, emu.RunFrames(context.Background(), 1))
+	_, err = movie.Play(emu, m, true)
+	assert.Error(t, err)
+}
+
+func TestPlaybackClock(t *testing.T) {
+	newEmulator := func() *emulator.Emulator {
+		rom := testutil.ROM(program...)
+		rom[0x147] = byte(cartridge.MBC3_TIMER_BATTERY)
+		cart, err := cartridge.FromBytes(rom)
+		require.NoError(t, err)
+
+		emu := emulator.New(cart)
+		emu.Pacer.SetUnthrottled(true)
+		return emu
+	}
+
+	emu := newEmulator()
+	emu.Cartridge.SetClock(1000)
+	rec, err := movie.Record(emu)
+	require.NoError(t, err)
+	require.NoError(t, emu.RunFrames(context.Background(), 60))
+	assert.Equal(t, int64(1000), rec.Movie().RTCSeed)
+
+	emu = newEmulator()
+	_, err = movie.Play(emu, rec.Movie(), true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), emu.Cartridge.Clock())
+	require.NoError(t, emu.RunFrames(context.Background(), 60), "checkpoints match")
+}
+
+func TestReadInvalid(t *testing.T) {
+	m := record(t, 10)
+	buf := &bytes.Buffer{}
+	_, err := m.WriteTo(buf)
+	require.NoError(t, err)
+	data := buf.Bytes()
+
+	for _, data := range [][]byte{nil, []byte("GBSS"), data[:len(data)-4]} {
+		_, err := movie.Read(bytes.NewReader(data))
+		assert.ErrorIs(t, err, movie.ErrorInvalidMovie)
+	}
+}
+
+func TestReadHugeFrameCount(t *testing.T) {
+	m := record(t, 10)
+	buf := &bytes.Buffer{}
+	_, err := m.WriteTo(buf)
+	require.NoError(t, err)
+
+	// magic, version and header, then the frame count
+	data := buf.Bytes()
+	at := 4 + 2 + binary.Size(m.Header)
+	binary.LittleEndian.PutUint32(data[at:], math.MaxUint32)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = movie.Read(bytes.NewReader(data))
+	runtime.ReadMemStats(&after)
+
+	assert.ErrorIs(t, err, movie.ErrorInvalidMovie)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20), "allocated for the frame count, not the data")
+}
@@ -0,0 +1,96 @@
+package movie
+
+import (
+	"fmt"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+)
+
+// DesyncError is returned when playback's state doesn't match a checkpoint
+type DesyncError struct {
+	Frame     uint32
+	Want, Got uint64
+}
+
+func (e *DesyncError) Error() string {
+	return fmt.Sprintf("movie desynced at frame %d: state hash %016X, want %016X", e.Frame, e.Got, e.Want)
+}
+
+// Player is a frame hook that feeds the movie's input to the joypad
+type Player struct {
+	movie *Movie
+	// Verify checks the state against the movie's checkpoints
+	Verify bool
+	// next frame to play, and the next checkpoint to check
+	frame      uint32
+	checkpoint int
+}
+
+// Play the movie on the emulator, which must be powered on with the same rom and save
+func Play(emu *emulator.Emulator, m *Movie, verify bool) (*Player, error) {
+	if emu.Frame() != 0 {
+		return nil, fmt.Errorf("movies must be played from power on, %d frames have run", emu.Frame())
+	}
+
+	h := HeaderFor(emu)
+	if h.ROMHash != m.ROMHash {
+		return nil, fmt.Errorf("%w: recorded with a different rom", ErrorMismatch)
+	}
+	if h.Model != m.Model {
+		return nil, fmt.Errorf("%w: recorded on model %q", ErrorMismatch, m.Model)
+	}
+	if h.SaveRAMHash != m.SaveRAMHash {
+		return nil, fmt.Errorf("%w: recorded with a different save", ErrorMismatch)
+	}
+
+	// the clock isn't checked, it's set to where the recording started
+	emu.Cartridge.SetClock(m.RTCSeed)
+
+	p := &Player{movie: m, Verify: verify}
+	emu.FrameHook = p
+	return p, nil
+}
+
+// Frame returns the number of frames played
+func (p *Player) Frame() uint32 {
+	return p.frame
+}
+
+// Done checks if every frame has been played
+func (p *Player) Done() bool {
+	return int(p.frame) >= len(p.movie.Inputs)
+}
+
+// BeforeFrame sets the joypad to the next frame's buttons, returning ErrorEnded when there are none left
+func (p *Player) BeforeFrame(emu *emulator.Emulator) error {
+	if p.Done() {
+		return ErrorEnded
+	}
+
+	emu.Joypad.SetPressed(p.movie.Inputs[p.frame])
+	p.frame++
+	return nil
+}
+
+func (p *Player) AfterFrame(emu *emulator.Emulator) error {
+	if !p.Verify || p.checkpoint >= len(p.movie.Checkpoints) {
+		return nil
+	}
+
+	want := p.movie.Checkpoints[p.checkpoint]
+	if want.Frame != p.frame {
+		return nil
+	}
+	p.checkpoint++
+
+	got, err := Hash(emu)
+	if err != nil {
+		return err
+	}
+
+	if got != want.Hash {
+		return &DesyncError{Frame: p.frame, Want: want.Hash, Got: got}
+	}
+
+	return nil
+}
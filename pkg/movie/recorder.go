@@ -0,0 +1,51 @@
+package movie
+
+import (
+	"fmt"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+)
+
+// Recorder is a frame hook that records the joypad into a movie
+type Recorder struct {
+	movie *Movie
+}
+
+// Record starts recording the emulator, which must be powered on and not have run yet
+func Record(emu *emulator.Emulator) (*Recorder, error) {
+	if emu.Frame() != 0 {
+		return nil, fmt.Errorf("movies must be recorded from power on, %d frames have run", emu.Frame())
+	}
+
+	r := &Recorder{
+		movie: &Movie{Header: HeaderFor(emu)},
+	}
+	emu.FrameHook = r
+	return r, nil
+}
+
+// Movie returns what has been recorded so far
+func (r *Recorder) Movie() *Movie {
+	return r.movie
+}
+
+// BeforeFrame records the buttons held for the frame, the frontend only changes them between frames
+func (r *Recorder) BeforeFrame(emu *emulator.Emulator) error {
+	r.movie.Inputs = append(r.movie.Inputs, emu.Joypad.Pressed())
+	return nil
+}
+
+func (r *Recorder) AfterFrame(emu *emulator.Emulator) error {
+	frame := uint32(len(r.movie.Inputs))
+	if frame%r.movie.CheckpointInterval != 0 {
+		return nil
+	}
+
+	hash, err := Hash(emu)
+	if err != nil {
+		return err
+	}
+
+	r.movie.Checkpoints = append(r.movie.Checkpoints, Checkpoint{frame, hash})
+	return nil
+}
@@ -0,0 +1,32 @@
+package ppu
+
+const (
+	// LCD control
+	LCDC_ADDRESS uint16 = 0xFF40
+	// LCD status
+	STAT_ADDRESS uint16 = 0xFF41
+	// Background viewport Y
+	SCY_ADDRESS uint16 = 0xFF42
+	// Background viewport X
+	SCX_ADDRESS uint16 = 0xFF43
+	// LCD Y coordinate
+	LY_ADDRESS uint16 = 0xFF44
+	// LY compare
+	LYC_ADDRESS uint16 = 0xFF45
+	// OAM DMA source, handled by the MMU
+	DMA_ADDRESS uint16 = 0xFF46
+	// Background palette
+	BGP_ADDRESS uint16 = 0xFF47
+	// Object palettes
+	OBP0_ADDRESS uint16 = 0xFF48
+	OBP1_ADDRESS uint16 = 0xFF49
+	// Window Y position
+	WY_ADDRESS uint16 = 0xFF4A
+	// Window X position plus 7
+	WX_ADDRESS uint16 = 0xFF4B
+
+	VRAM_OFFSET uint16 = 0x8000
+	VRAM_SIZE          = 0x2000
+	OAM_OFFSET  uint16 = 0xFE00
+	OAM_SIZE           = 0xA0
+)
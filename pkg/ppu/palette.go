@@ -0,0 +1,79 @@
+package ppu
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strings"
+)
+
+// Palette is the colors the four shades are displayed as, lightest first
+type Palette [4]color.RGBA
+
+var (
+	// PaletteGray is plain grayscale
+	PaletteGray = Palette{
+		{0xFF, 0xFF, 0xFF, 0xFF},
+		{0xAA, 0xAA, 0xAA, 0xFF},
+		{0x55, 0x55, 0x55, 0xFF},
+		{0x00, 0x00, 0x00, 0xFF},
+	}
+	// PaletteGreen is the green tint of the original DMG screen
+	PaletteGreen = Palette{
+		{0x9B, 0xBC, 0x0F, 0xFF},
+		{0x8B, 0xAC, 0x0F, 0xFF},
+		{0x30, 0x62, 0x30, 0xFF},
+		{0x0F, 0x38, 0x0F, 0xFF},
+	}
+	// PalettePocket is the neutral tone of the Game Boy Pocket screen
+	PalettePocket = Palette{
+		{0xC4, 0xCF, 0xA1, 0xFF},
+		{0x8B, 0x95, 0x6D, 0xFF},
+		{0x4D, 0x53, 0x3C, 0xFF},
+		{0x1F, 0x1F, 0x1F, 0xFF},
+	}
+
+	Palettes = map[string]Palette{
+		"gray":   PaletteGray,
+		"green":  PaletteGreen,
+		"pocket": PalettePocket,
+	}
+)
+
+// PaletteNames returns the names of the palettes, sorted
+func PaletteNames() []string {
+	names := make([]string, 0, len(Palettes))
+	for name := range Palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// PaletteByName looks up a palette in Palettes
+func PaletteByName(name string) (Palette, error) {
+	p, ok := Palettes[strings.ToLower(name)]
+	if !ok {
+		return Palette{}, fmt.Errorf("unknown palette %q, expected one of: %s", name, strings.Join(PaletteNames(), ", "))
+	}
+
+	return p, nil
+}
+
+// Image renders the frame in the palette, with each pixel scaled up to a scale x scale square
+func (fb *Framebuffer) Image(p Palette, scale int) *image.RGBA {
+	if scale < 1 {
+		scale = 1
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, Width*scale, Height*scale))
+	for y := 0; y < Height*scale; y++ {
+		for x := 0; x < Width*scale; x++ {
+			img.SetRGBA(x, y, p[fb[(y/scale)*Width+x/scale]&0b11])
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,330 @@
+package ppu
+
+import (
+	"github.com/robherley/go-gameboy/internal/bits"
+	errs "github.com/robherley/go-gameboy/pkg/errors"
+)
+
+// https://gbdev.io/pandocs/Rendering.html
+
+const (
+	Width  = 160
+	Height = 144
+
+	// dots (T-cycles) per scanline, and the number of scanlines including vblank
+	dotsPerLine = 456
+	lines       = 154
+	// mode 3 varies from 172 to 289 dots on hardware, it's fixed here since lines are drawn all at once
+	oamScanDots = 80
+	drawingDots = 172
+)
+
+// Mode is the PPU mode, in the lower bits of STAT
+// https://gbdev.io/pandocs/STAT.html
+type Mode byte
+
+const (
+	ModeHBlank Mode = iota
+	ModeVBlank
+	ModeOAMScan
+	ModeDrawing
+)
+
+// LCDC bits
+// https://gbdev.io/pandocs/LCDC.html
+const (
+	lcdcBGEnable     = 1 << 0
+	lcdcOBJEnable    = 1 << 1
+	lcdcOBJSize      = 1 << 2
+	lcdcBGTileMap    = 1 << 3
+	lcdcTileData     = 1 << 4
+	lcdcWindowEnable = 1 << 5
+	lcdcWindowMap    = 1 << 6
+	lcdcEnable       = 1 << 7
+)
+
+// STAT bits
+const (
+	statCoincidence       = 1 << 2
+	statHBlankSource      = 1 << 3
+	statVBlankSource      = 1 << 4
+	statOAMSource         = 1 << 5
+	statLYCSource         = 1 << 6
+	statWritable          = statHBlankSource | statVBlankSource | statOAMSource | statLYCSource
+	statModeMask     byte = 0b11
+)
+
+// Framebuffer is a frame of shades, 0 (lightest) to 3 (darkest), row by row
+type Framebuffer [Width * Height]byte
+
+type PPU struct {
+	// FF40 - LCD control
+	LCDC byte
+	// FF41 - LCD status, the mode and coincidence flag are read only
+	STAT byte
+	// FF42/FF43 - Background viewport position
+	SCY, SCX byte
+	// FF44 - Current scanline
+	LY byte
+	// FF45 - LY compare
+	LYC byte
+	// FF47-FF49 - Palettes, 2 bits per color
+	BGP, OBP0, OBP1 byte
+	// FF4A/FF4B - Window position
+	WY, WX byte
+	// FakeLY makes LY always read 0x90 (the start of vblank), gameboy-doctor logs are captured this way
+	FakeLY bool
+
+	vram [VRAM_SIZE]byte
+	oam  [OAM_SIZE]byte
+	// dot in the current scanline
+	dot int
+	// the window has its own line counter, it's only incremented on lines it's drawn
+	windowLine byte
+	// STAT interrupts are requested on the rising edge of all the sources OR'd together
+	statLine bool
+	// back is drawn to, it's copied to front when the frame is done
+	front, back Framebuffer
+
+	// Callbacks for interrupts
+	OnVBlank func()
+	OnSTAT   func()
+}
+
+func New(vblankFunc, statFunc func()) *PPU {
+	return &PPU{
+		// post boot rom values
+		LCDC:     0x91,
+		STAT:     byte(ModeOAMScan),
+		BGP:      0xFC,
+		OnVBlank: vblankFunc,
+		OnSTAT:   statFunc,
+	}
+}
+
+// Framebuffer returns the last completed frame
+func (p *PPU) Framebuffer() *Framebuffer {
+	return &p.front
+}
+
+// Mode returns the current mode
+func (p *PPU) Mode() Mode {
+	return Mode(p.STAT & statModeMask)
+}
+
+func (p *PPU) setMode(m Mode) {
+	p.STAT = p.STAT&^statModeMask | byte(m)
+}
+
+// Tick advances the PPU by a single dot (T-cycle)
+func (p *PPU) Tick() {
+	if p.LCDC&lcdcEnable == 0 {
+		return
+	}
+
+	p.dot++
+
+	if p.LY < Height {
+		switch p.dot {
+		case oamScanDots:
+			p.setMode(ModeDrawing)
+		case oamScanDots + drawingDots:
+			p.renderLine()
+			p.setMode(ModeHBlank)
+		}
+	}
+
+	if p.dot == dotsPerLine {
+		p.dot = 0
+		p.LY++
+
+		switch {
+		case p.LY == Height:
+			p.setMode(ModeVBlank)
+			p.front = p.back
+			if p.OnVBlank != nil {
+				p.OnVBlank()
+			}
+		case p.LY == lines:
+			p.LY = 0
+			p.windowLine = 0
+			p.setMode(ModeOAMScan)
+		case p.LY < Height:
+			p.setMode(ModeOAMScan)
+		}
+	}
+
+	p.updateSTAT()
+}
+
+// updateSTAT sets the coincidence flag, requesting the interrupt if any enabled source became active
+func (p *PPU) updateSTAT() {
+	if p.LY == p.LYC {
+		p.STAT |= statCoincidence
+	} else {
+		p.STAT &^= statCoincidence
+	}
+
+	mode := p.Mode()
+	line := (p.STAT&statLYCSource != 0 && p.LY == p.LYC) ||
+		(p.STAT&statHBlankSource != 0 && mode == ModeHBlank) ||
+		(p.STAT&statVBlankSource != 0 && mode == ModeVBlank) ||
+		(p.STAT&statOAMSource != 0 && mode == ModeOAMScan)
+
+	if line && !p.statLine && p.OnSTAT != nil {
+		p.OnSTAT()
+	}
+	p.statLine = line
+}
+
+func (p *PPU) Read(address uint16) byte {
+	switch {
+	case address >= VRAM_OFFSET && address < VRAM_OFFSET+VRAM_SIZE:
+		return p.vram[address-VRAM_OFFSET]
+	case address >= OAM_OFFSET && address < OAM_OFFSET+OAM_SIZE:
+		return p.oam[address-OAM_OFFSET]
+	}
+
+	switch address {
+	case LCDC_ADDRESS:
+		return p.LCDC
+	case STAT_ADDRESS:
+		// bit 7 is unused and reads as 1
+		return p.STAT | 0x80
+	case SCY_ADDRESS:
+		return p.SCY
+	case SCX_ADDRESS:
+		return p.SCX
+	case LY_ADDRESS:
+		if p.FakeLY {
+			return 0x90
+		}
+		return p.LY
+	case LYC_ADDRESS:
+		return p.LYC
+	case BGP_ADDRESS:
+		return p.BGP
+	case OBP0_ADDRESS:
+		return p.OBP0
+	case OBP1_ADDRESS:
+		return p.OBP1
+	case WY_ADDRESS:
+		return p.WY
+	case WX_ADDRESS:
+		return p.WX
+	default:
+		panic(errs.NewReadError(address, "ppu"))
+	}
+}
+
+func (p *PPU) Write(address uint16, data byte) {
+	switch {
+	case address >= VRAM_OFFSET && address < VRAM_OFFSET+VRAM_SIZE:
+		p.vram[address-VRAM_OFFSET] = data
+		return
+	case address >= OAM_OFFSET && address < OAM_OFFSET+OAM_SIZE:
+		p.oam[address-OAM_OFFSET] = data
+		return
+	}
+
+	switch address {
+	case LCDC_ADDRESS:
+		p.writeLCDC(data)
+	case STAT_ADDRESS:
+		p.STAT = p.STAT&^statWritable | data&statWritable
+		p.updateSTAT()
+	case SCY_ADDRESS:
+		p.SCY = data
+	case SCX_ADDRESS:
+		p.SCX = data
+	case LY_ADDRESS:
+		// read only
+	case LYC_ADDRESS:
+		p.LYC = data
+		if p.LCDC&lcdcEnable != 0 {
+			p.updateSTAT()
+		}
+	case BGP_ADDRESS:
+		p.BGP = data
+	case OBP0_ADDRESS:
+		p.OBP0 = data
+	case OBP1_ADDRESS:
+		p.OBP1 = data
+	case WY_ADDRESS:
+		p.WY = data
+	case WX_ADDRESS:
+		p.WX = data
+	default:
+		panic(errs.NewWriteError(address, "ppu"))
+	}
+}
+
+// writeLCDC turns the LCD on and off. While it's off LY stays at 0 and the screen is blank
+func (p *PPU) writeLCDC(data byte) {
+	wasEnabled := p.LCDC&lcdcEnable != 0
+	p.LCDC = data
+
+	switch enabled := data&lcdcEnable != 0; {
+	case wasEnabled && !enabled:
+		p.LY = 0
+		p.dot = 0
+		p.windowLine = 0
+		p.statLine = false
+		p.setMode(ModeHBlank)
+		p.back = Framebuffer{}
+		p.front = Framebuffer{}
+	case !wasEnabled && enabled:
+		p.setMode(ModeOAMScan)
+		p.updateSTAT()
+	}
+}
+
+// registers returns pointers to the registers in the order they're saved
+func (p *PPU) registers() []*byte {
+	return []*byte{&p.LCDC, &p.STAT, &p.SCY, &p.SCX, &p.LY, &p.LYC, &p.BGP, &p.OBP0, &p.OBP1, &p.WY, &p.WX}
+}
+
+// registers, dot (2 bytes), window line, stat line, vram, oam and both framebuffers
+const stateSize = 11 + 4 + VRAM_SIZE + OAM_SIZE + 2*Width*Height
+
+// MarshalBinary encodes the registers, memory and frames for save states
+func (p *PPU) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, stateSize)
+	for _, r := range p.registers() {
+		data = append(data, *r)
+	}
+
+	statLine := byte(0)
+	if p.statLine {
+		statLine = 1
+	}
+
+	data = append(data, bits.Lo(uint16(p.dot)), bits.Hi(uint16(p.dot)), p.windowLine, statLine)
+	data = append(data, p.vram[:]...)
+	data = append(data, p.oam[:]...)
+	data = append(data, p.front[:]...)
+	return append(data, p.back[:]...), nil
+}
+
+// UnmarshalBinary restores the ppu from MarshalBinary
+func (p *PPU) UnmarshalBinary(data []byte) error {
+	if len(data) != stateSize {
+		return errs.NewInvalidStateError("ppu", len(data))
+	}
+
+	for _, r := range p.registers() {
+		*r = data[0]
+		data = data[1:]
+	}
+
+	p.dot = int(bits.To16(data[1], data[0]))
+	p.windowLine = data[2]
+	p.statLine = data[3] != 0
+	data = data[4:]
+
+	data = data[copy(p.vram[:], data):]
+	data = data[copy(p.oam[:], data):]
+	data = data[copy(p.front[:], data):]
+	copy(p.back[:], data)
+	return nil
+}
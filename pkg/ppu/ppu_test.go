@@ -0,0 +1,139 @@
+package ppu_test
+
+import (
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/ppu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dotsPerFrame = 70224
+
+func tick(p *ppu.PPU, dots int) {
+	for i := 0; i < dots; i++ {
+		p.Tick()
+	}
+}
+
+func TestTiming(t *testing.T) {
+	vblanks, stats := 0, 0
+	p := ppu.New(func() { vblanks++ }, func() { stats++ })
+
+	tests := []struct {
+		name string
+		dots int
+		ly   byte
+		mode ppu.Mode
+	}{
+		{"oam scan", 79, 0, ppu.ModeOAMScan},
+		{"drawing", 1, 0, ppu.ModeDrawing},
+		{"hblank", 172, 0, ppu.ModeHBlank},
+		{"next line", 456 - 252, 1, ppu.ModeOAMScan},
+		{"vblank", 143 * 456, 144, ppu.ModeVBlank},
+		{"last line", 9 * 456, 153, ppu.ModeVBlank},
+		{"next frame", 456, 0, ppu.ModeOAMScan},
+	}
+
+	for _, tc := range tests {
+		tick(p, tc.dots)
+		assert.Equal(t, tc.ly, p.Read(ppu.LY_ADDRESS), tc.name)
+		assert.Equal(t, tc.mode, p.Mode(), tc.name)
+	}
+
+	assert.Equal(t, 1, vblanks)
+	assert.Equal(t, 0, stats)
+}
+
+func TestSTAT(t *testing.T) {
+	stats := 0
+	p := ppu.New(nil, func() { stats++ })
+
+	// LYC coincidence, once per frame
+	p.Write(ppu.LYC_ADDRESS, 10)
+	p.Write(ppu.STAT_ADDRESS, 1<<6)
+	tick(p, dotsPerFrame)
+	assert.Equal(t, 1, stats)
+
+	// the mode and coincidence bits are read only
+	p.Write(ppu.STAT_ADDRESS, 0xFF)
+	assert.Equal(t, byte(0xF8), p.Read(ppu.STAT_ADDRESS)&0xF8)
+	assert.Equal(t, byte(ppu.ModeOAMScan), p.Read(ppu.STAT_ADDRESS)&0b11)
+
+	// every source enabled: the line only drops while drawing, so it rises once per visible line
+	// entering hblank. Except line 10, where LYC keeps it high while drawing
+	stats = 0
+	tick(p, dotsPerFrame)
+	assert.Equal(t, 143, stats)
+}
+
+func TestLCDOff(t *testing.T) {
+	p := ppu.New(nil, nil)
+	tick(p, 456*10)
+	require.Equal(t, byte(10), p.Read(ppu.LY_ADDRESS))
+
+	p.Write(ppu.LCDC_ADDRESS, 0x11)
+	tick(p, 456*10)
+	assert.Equal(t, byte(0), p.Read(ppu.LY_ADDRESS))
+	assert.Equal(t, ppu.ModeHBlank, p.Mode())
+
+	p.FakeLY = true
+	assert.Equal(t, byte(0x90), p.Read(ppu.LY_ADDRESS))
+}
+
+func TestRender(t *testing.T) {
+	p := ppu.New(nil, nil)
+
+	// tile 1 is a vertical stripe of each color in columns 0-3, then color 0
+	for row := 0; row < 8; row++ {
+		p.Write(0x8010+uint16(row*2), 0b01010000)
+		p.Write(0x8011+uint16(row*2), 0b00110000)
+	}
+	// tile 2 is solid color 3
+	for i := uint16(0); i < 16; i++ {
+		p.Write(0x8020+i, 0xFF)
+	}
+
+	// the top left tile of the map, scrolled over by a pixel
+	p.Write(0x9800, 1)
+	p.Write(ppu.SCX_ADDRESS, 1)
+	p.Write(ppu.BGP_ADDRESS, 0b11100100)
+
+	// a sprite at 20,20 with tile 2, and one behind the background at 40,20
+	sprites := []byte{
+		16 + 20, 8 + 20, 2, 0,
+		16 + 20, 8 + 40, 2, 1 << 7,
+	}
+	for i, b := range sprites {
+		p.Write(ppu.OAM_OFFSET+uint16(i), b)
+	}
+	p.Write(ppu.OBP0_ADDRESS, 0b10000000)
+	// 8000 addressing, background and sprites on
+	p.Write(ppu.LCDC_ADDRESS, 0x93)
+
+	tick(p, dotsPerFrame)
+	fb := p.Framebuffer()
+
+	assert.Equal(t, []byte{1, 2, 3, 0, 0, 0, 0, 0}, fb[0:8])
+	assert.Equal(t, []byte{1, 2, 3, 0}, fb[7*ppu.Width:7*ppu.Width+4])
+	assert.Equal(t, byte(0), fb[8*ppu.Width])
+
+	// color 3 through OBP0 is shade 2
+	assert.Equal(t, byte(2), fb[20*ppu.Width+20])
+	assert.Equal(t, byte(2), fb[27*ppu.Width+27])
+	assert.Equal(t, byte(0), fb[28*ppu.Width+20])
+	// behind background color 0 it still shows
+	assert.Equal(t, byte(2), fb[20*ppu.Width+40])
+}
+
+func TestImage(t *testing.T) {
+	p := ppu.New(nil, nil)
+	img := p.Framebuffer().Image(ppu.PaletteGreen, 3)
+
+	assert.Equal(t, ppu.Width*3, img.Bounds().Dx())
+	assert.Equal(t, ppu.Height*3, img.Bounds().Dy())
+	assert.Equal(t, ppu.PaletteGreen[0], img.RGBAAt(0, 0))
+
+	_, err := ppu.PaletteByName("nope")
+	assert.Error(t, err)
+}
@@ -0,0 +1,165 @@
+package ppu
+
+// Scanlines are drawn all at once at the end of mode 3, which is accurate enough for games that
+// don't change registers mid-line.
+
+// sprite attribute flags
+// https://gbdev.io/pandocs/OAM.html
+const (
+	attrPalette  = 1 << 4
+	attrFlipX    = 1 << 5
+	attrFlipY    = 1 << 6
+	attrPriority = 1 << 7
+)
+
+// at most 10 sprites are drawn per line
+const maxSpritesPerLine = 10
+
+func (p *PPU) renderLine() {
+	row := p.back[int(p.LY)*Width:][:Width]
+	// background color numbers (before the palette), sprites can be drawn behind 1-3
+	var bgColors [Width]byte
+
+	if p.LCDC&lcdcBGEnable != 0 {
+		p.renderBackground(row, &bgColors)
+	} else {
+		for x := range row {
+			row[x] = 0
+		}
+	}
+
+	if p.LCDC&lcdcOBJEnable != 0 {
+		p.renderSprites(row, &bgColors)
+	}
+}
+
+// renderBackground draws the background and window
+// https://gbdev.io/pandocs/Scrolling.html
+func (p *PPU) renderBackground(row []byte, bgColors *[Width]byte) {
+	bgMap := tileMap(p.LCDC&lcdcBGTileMap != 0)
+	windowMap := tileMap(p.LCDC&lcdcWindowMap != 0)
+	window := p.LCDC&lcdcWindowEnable != 0 && p.WY <= p.LY && p.WX <= 166
+	drewWindow := false
+
+	for x := 0; x < Width; x++ {
+		var color byte
+		if window && x+7 >= int(p.WX) {
+			color = p.tilePixel(windowMap, byte(x+7-int(p.WX)), p.windowLine)
+			drewWindow = true
+		} else {
+			color = p.tilePixel(bgMap, byte(x)+p.SCX, p.LY+p.SCY)
+		}
+
+		bgColors[x] = color
+		row[x] = shade(p.BGP, color)
+	}
+
+	if drewWindow {
+		p.windowLine++
+	}
+}
+
+// tileMap returns the offset of the 32x32 tile map in vram
+func tileMap(high bool) int {
+	if high {
+		return 0x1C00
+	}
+	return 0x1800
+}
+
+// tilePixel returns the color number at the pixel in a tile map
+// https://gbdev.io/pandocs/Tile_Data.html
+func (p *PPU) tilePixel(tileMap int, x, y byte) byte {
+	tile := p.vram[tileMap+int(y/8)*32+int(x/8)]
+
+	// 0x8000 addressing uses unsigned tile numbers, 0x8800 addressing is signed from 0x9000
+	addr := int(tile) * 16
+	if p.LCDC&lcdcTileData == 0 {
+		addr = 0x1000 + int(int8(tile))*16
+	}
+
+	return p.tileData(addr, y%8, x%8)
+}
+
+// tileData returns the color number of a pixel in the tile at addr
+func (p *PPU) tileData(addr int, row, col byte) byte {
+	lo := p.vram[addr+int(row)*2]
+	hi := p.vram[addr+int(row)*2+1]
+	bit := 7 - col
+
+	return (hi>>bit&1)<<1 | lo>>bit&1
+}
+
+// renderSprites draws the objects on the current line
+// https://gbdev.io/pandocs/OAM.html#drawing-priority
+func (p *PPU) renderSprites(row []byte, bgColors *[Width]byte) {
+	height := 8
+	if p.LCDC&lcdcOBJSize != 0 {
+		height = 16
+	}
+
+	// the first 10 sprites in OAM that are on this line
+	selected := make([]int, 0, maxSpritesPerLine)
+	for i := 0; i < OAM_SIZE && len(selected) < maxSpritesPerLine; i += 4 {
+		top := int(p.oam[i]) - 16
+		if int(p.LY) >= top && int(p.LY) < top+height {
+			selected = append(selected, i)
+		}
+	}
+
+	// lower X is drawn on top, ties go to the first in OAM. Insertion sort keeps it stable
+	for i := 1; i < len(selected); i++ {
+		for j := i; j > 0 && p.oam[selected[j]+1] < p.oam[selected[j-1]+1]; j-- {
+			selected[j], selected[j-1] = selected[j-1], selected[j]
+		}
+	}
+
+	var drawn [Width]bool
+	for _, i := range selected {
+		y, x, tile, attrs := int(p.oam[i])-16, int(p.oam[i+1])-8, p.oam[i+2], p.oam[i+3]
+
+		line := int(p.LY) - y
+		if attrs&attrFlipY != 0 {
+			line = height - 1 - line
+		}
+		if height == 16 {
+			tile &= 0xFE
+		}
+
+		palette := p.OBP0
+		if attrs&attrPalette != 0 {
+			palette = p.OBP1
+		}
+
+		for col := 0; col < 8; col++ {
+			px := x + col
+			if px < 0 || px >= Width || drawn[px] {
+				continue
+			}
+
+			c := col
+			if attrs&attrFlipX != 0 {
+				c = 7 - col
+			}
+
+			// 8x16 sprites continue into the next tile, which follows in memory
+			color := p.tileData(int(tile)*16, byte(line), byte(c))
+			if color == 0 {
+				continue
+			}
+
+			// the highest priority sprite wins even when it's behind the background
+			drawn[px] = true
+			if attrs&attrPriority != 0 && bgColors[px] != 0 {
+				continue
+			}
+
+			row[px] = shade(palette, color)
+		}
+	}
+}
+
+// shade maps a color number through a palette
+func shade(palette, color byte) byte {
+	return palette >> (color * 2) & 0b11
+}
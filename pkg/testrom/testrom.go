@@ -0,0 +1,217 @@
+package testrom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/robherley/go-gameboy/pkg/cpu"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+)
+
+// Runs test roms headlessly until they report a result. Supports:
+//   - blargg's test roms, which print "Passed" or "Failed" over the serial port
+//     https://github.com/retrio/gb-test-roms
+//   - mooneye's test suite, which executes LD B,B when finished with the fibonacci
+//     sequence in the registers on success, or 0x42 in all of them on failure
+//     https://github.com/Gekkio/mooneye-test-suite
+//
+// Blargg's roms run LD B,B while testing too (ie: cpu_instrs' ld r,r), so unless the suite is
+// known to be mooneye's the breakpoint only ends the test with one of mooneye's signatures.
+
+type Status string
+
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusTimeout Status = "timeout"
+	StatusError   Status = "error"
+)
+
+// Suite is the test suite a rom is from, it decides how the end of a test is detected
+type Suite string
+
+const (
+	// SuiteAuto detects either suite's results, mooneye's breakpoint only ends the test when the
+	// registers hold its pass or fail signature
+	SuiteAuto Suite = "auto"
+	// SuiteBlargg only checks the serial output
+	SuiteBlargg Suite = "blargg"
+	// SuiteMooneye only checks the breakpoint, anything but the pass signature fails
+	SuiteMooneye Suite = "mooneye"
+)
+
+// Suites are the suites that can be picked by name
+var Suites = []Suite{SuiteAuto, SuiteBlargg, SuiteMooneye}
+
+// SuiteByName returns the suite with the name
+func SuiteByName(name string) (Suite, error) {
+	for _, suite := range Suites {
+		if string(suite) == name {
+			return suite, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown suite %q, must be auto, blargg or mooneye", name)
+}
+
+// DefaultMaxCycles is two minutes of emulated time, cpu_instrs is the slowest and takes under a minute
+const DefaultMaxCycles = 4194304 * 120
+
+// opcode for LD B,B, used by mooneye as a software breakpoint
+const breakpointOpcode = 0x40
+
+type Options struct {
+	// MaxCycles is the budget of T-cycles to run before timing out
+	MaxCycles uint64
+	// Serial optionally receives a copy of the serial output as it's written
+	Serial io.Writer
+	// Suite the rom is from, SuiteAuto if it isn't set
+	Suite Suite
+}
+
+type Result struct {
+	ROM    string `json:"rom"`
+	Status Status `json:"status"`
+	// Cycles is the number of T-cycles run
+	Cycles uint64 `json:"cycles"`
+	// Serial is everything the rom sent over the serial port
+	Serial string `json:"serial,omitempty"`
+	// Error is set when the emulator faulted
+	Error string `json:"error,omitempty"`
+}
+
+// Passed checks if the rom reported success
+func (r *Result) Passed() bool {
+	return r.Status == StatusPassed
+}
+
+// RunFile loads the rom at path and runs it
+func RunFile(path string, opts Options) (Result, error) {
+	cart, err := cartridge.FromFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Run(cart, opts)
+	result.ROM = path
+	return result, nil
+}
+
+// Run the cartridge until it reports a result or the cycle budget runs out
+func Run(cart *cartridge.Cartridge, opts Options) (result Result) {
+	if opts.MaxCycles == 0 {
+		opts.MaxCycles = DefaultMaxCycles
+	}
+	if opts.Suite == "" {
+		opts.Suite = SuiteAuto
+	}
+
+	serial := &bytes.Buffer{}
+	d := &detector{serial: serial, suite: opts.Suite}
+
+	emu := emulator.New(cart)
+	emu.Tracer = d
+	if opts.Serial != nil {
+		emu.MMU.SetSerialOutput(io.MultiWriter(serial, opts.Serial))
+	} else {
+		emu.MMU.SetSerialOutput(serial)
+	}
+
+	defer func() {
+		result.Cycles = emu.CPU.Ticks
+		result.Serial = serial.String()
+	}()
+
+	for emu.CPU.Ticks < opts.MaxCycles {
+		if err := emu.Step(); err != nil {
+			return Result{Status: StatusError, Error: err.Error()}
+		}
+
+		if d.status != "" {
+			return Result{Status: d.status}
+		}
+	}
+
+	return Result{Status: StatusTimeout}
+}
+
+// Require runs the rom at path as a subtest helper, failing the test unless it passes.
+// Tests are skipped if the rom doesn't exist, test roms aren't checked in.
+func Require(t testing.TB, path string, opts Options) {
+	t.Helper()
+
+	result, err := RunFile(path, opts)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skipf("test rom not found: %s", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Passed() {
+		t.Fatalf("%s: %s after %d cycles %s\n%s", filepath.Base(path), result.Status, result.Cycles, result.Error, result.Serial)
+	}
+}
+
+// Find returns the roms (.gb files) in dir and its subdirectories, sorted by path
+func Find(dir string) ([]string, error) {
+	roms := []string{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".gb") {
+			roms = append(roms, path)
+		}
+		return nil
+	})
+
+	return roms, err
+}
+
+// detector watches execution for the end of a test
+type detector struct {
+	suite  Suite
+	serial *bytes.Buffer
+	// how much serial output has been checked
+	checked int
+	status  Status
+}
+
+func (d *detector) Trace(c *cpu.CPU) {
+	// blargg
+	if d.suite != SuiteMooneye && d.serial.Len() != d.checked {
+		d.checked = d.serial.Len()
+
+		if out := d.serial.Bytes(); bytes.Contains(out, []byte("Passed")) {
+			d.status = StatusPassed
+			return
+		} else if bytes.Contains(out, []byte("Failed")) {
+			d.status = StatusFailed
+			return
+		}
+	}
+
+	// mooneye
+	if d.suite == SuiteBlargg || c.Bus.Read8(c.Registers.PC) != breakpointOpcode {
+		return
+	}
+
+	r := c.Registers
+	switch {
+	case r.B == 3 && r.C == 5 && r.D == 8 && r.E == 13 && r.H == 21 && r.L == 34:
+		d.status = StatusPassed
+	case d.suite == SuiteMooneye:
+		d.status = StatusFailed
+	case r.B == 0x42 && r.C == 0x42 && r.D == 0x42 && r.E == 0x42 && r.H == 0x42 && r.L == 0x42:
+		d.status = StatusFailed
+	}
+}
@@ -0,0 +1,111 @@
+package testrom_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robherley/go-gameboy/internal/testutil"
+	"github.com/robherley/go-gameboy/pkg/cartridge"
+	"github.com/robherley/go-gameboy/pkg/golden"
+	"github.com/robherley/go-gameboy/pkg/testrom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// romDir is where test roms are kept, set by GB_TEST_ROMS or roms/ at the root of the repo
+func romDir() string {
+	if dir := os.Getenv("GB_TEST_ROMS"); dir != "" {
+		return dir
+	}
+
+	return filepath.Join("..", "..", "roms")
+}
+
+func TestBlargg(t *testing.T) {
+	roms := []string{
+		"blargg/cpu_instrs/cpu_instrs.gb",
+		"blargg/instr_timing/instr_timing.gb",
+	}
+
+	for _, rom := range roms {
+		t.Run(rom, func(t *testing.T) {
+			testrom.Require(t, filepath.Join(romDir(), rom), testrom.Options{Suite: testrom.SuiteBlargg})
+		})
+	}
+}
+
+func TestMooneyeAcceptance(t *testing.T) {
+	roms, err := testrom.Find(filepath.Join(romDir(), "mooneye", "acceptance"))
+	if err != nil || len(roms) == 0 {
+		t.Skip("mooneye acceptance roms not found")
+	}
+
+	for _, rom := range roms {
+		t.Run(filepath.Base(rom), func(t *testing.T) {
+			testrom.Require(t, rom, testrom.Options{Suite: testrom.SuiteMooneye})
+		})
+	}
+}
+
+// breakpointThenPassed runs LD B,B like blargg's ld r,r test, then prints "Passed"
+var breakpointThenPassed = []byte{
+	0x40,             // LD B,B
+	0x21, 0x50, 0x01, // LD HL,$0150
+	0x2A,       // loop: LD A,(HL+)
+	0xB7,       // OR A
+	0x28, 0x08, // JR Z,done
+	0xE0, 0x01, // LDH ($01),A
+	0x3E, 0x81, // LD A,$81
+	0xE0, 0x02, // LDH ($02),A
+	0x18, 0xF4, // JR loop
+	0x18, 0xFE, // done: JR -2
+}
+
+// fibonacci loads mooneye's pass signature and hits the breakpoint
+var fibonacci = []byte{
+	0x06, 0x03, // LD B,3
+	0x0E, 0x05, // LD C,5
+	0x16, 0x08, // LD D,8
+	0x1E, 0x0D, // LD E,13
+	0x26, 0x15, // LD H,21
+	0x2E, 0x22, // LD L,34
+	0x40,       // LD B,B
+	0x18, 0xFE, // JR -2
+}
+
+func TestSuites(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		suite   testrom.Suite
+		want    testrom.Status
+	}{
+		{"breakpoint then passed", breakpointThenPassed, testrom.SuiteAuto, testrom.StatusPassed},
+		{"breakpoint then passed, blargg", breakpointThenPassed, testrom.SuiteBlargg, testrom.StatusPassed},
+		{"breakpoint then passed, mooneye", breakpointThenPassed, testrom.SuiteMooneye, testrom.StatusFailed},
+		{"fibonacci", fibonacci, testrom.SuiteAuto, testrom.StatusPassed},
+		{"fibonacci, mooneye", fibonacci, testrom.SuiteMooneye, testrom.StatusPassed},
+		{"fibonacci, blargg", fibonacci, testrom.SuiteBlargg, testrom.StatusTimeout},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rom := testutil.ROM(tc.program...)
+			copy(rom[0x150:], "Passed\n")
+
+			cart, err := cartridge.FromBytes(rom)
+			require.NoError(t, err)
+
+			result := testrom.Run(cart, testrom.Options{MaxCycles: 10000, Suite: tc.suite})
+			assert.Equal(t, tc.want, result.Status, result.Serial)
+		})
+	}
+}
+
+// TestDMGAcid2 compares against the reference image from the dmg-acid2 repo, kept next to the rom
+// https://github.com/mattcurrie/dmg-acid2
+func TestDMGAcid2(t *testing.T) {
+	dir := filepath.Join(romDir(), "dmg-acid2")
+	golden.Require(t, filepath.Join(dir, "dmg-acid2.gb"), filepath.Join(dir, "reference-dmg.png"), golden.Options{Frames: 60})
+}
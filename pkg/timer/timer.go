@@ -6,6 +6,7 @@ import (
 )
 
 // https://gbdev.io/pandocs/Timer_and_Divider_Registers.html
+// https://gbdev.io/pandocs/Timer_Obscure_Behaviour.html
 type Timer struct {
 	// FF04 - Divider register, interal system 16bit counter
 	DIV uint16
@@ -17,6 +18,33 @@ type Timer struct {
 	TAC byte
 	// Callback for interrupt
 	OnInterrupt func()
+	// OnFrameSequencer is called when bit 4 of DIV falls, which clocks the APU's frame sequencer
+	OnFrameSequencer func()
+	// T-cycles left until TMA is loaded after TIMA overflows, TIMA reads as 0x00 until then
+	overflow byte
+	// T-cycles left in the cycle TMA is loaded into TIMA
+	reloading byte
+}
+
+// TIMA is reloaded one M-cycle after it overflows
+const reloadDelay = 4
+
+// TAC bit 2 enables the timer
+const tacEnable = 1 << 2
+
+// bit 4 of DIV, 512 Hz
+const frameSequencerBit = 1 << 12
+
+// DIV bit that is selected by the lower two bits of TAC, TIMA increments when it falls
+var tacBits = [4]byte{
+	// 00: CPU Clock / 1024 (DMG, SGB2, CGB Single Speed Mode:   4096 Hz, SGB1:   ~4194 Hz, CGB Double Speed Mode:   8192 Hz)
+	9,
+	// 01: CPU Clock / 16   (DMG, SGB2, CGB Single Speed Mode: 262144 Hz, SGB1: ~268400 Hz, CGB Double Speed Mode: 524288 Hz)
+	3,
+	// 10: CPU Clock / 64   (DMG, SGB2, CGB Single Speed Mode:  65536 Hz, SGB1:  ~67110 Hz, CGB Double Speed Mode: 131072 Hz)
+	5,
+	// 11: CPU Clock / 256  (DMG, SGB2, CGB Single Speed Mode:  16384 Hz, SGB1:  ~16780 Hz, CGB Double Speed Mode:  32768 Hz)
+	7,
 }
 
 func New(interruptFunc func()) *Timer {
@@ -29,42 +57,50 @@ func New(interruptFunc func()) *Timer {
 	}
 }
 
+// Tick advances the timer by a single T-cycle
 func (t *Timer) Tick() {
-	prevDiv := t.DIV
-	t.DIV++
-
-	timerUpdate := false
-
-	switch t.TAC & 0b11 {
-	case 0b00:
-		// 00: CPU Clock / 1024 (DMG, SGB2, CGB Single Speed Mode:   4096 Hz, SGB1:   ~4194 Hz, CGB Double Speed Mode:   8192 Hz)
-		timerUpdate = checkBit(prevDiv, t.DIV, 9)
-	case 0b01:
-		// 01: CPU Clock / 16   (DMG, SGB2, CGB Single Speed Mode: 262144 Hz, SGB1: ~268400 Hz, CGB Double Speed Mode: 524288 Hz)
-		timerUpdate = checkBit(prevDiv, t.DIV, 3)
-	case 0b10:
-		// 10: CPU Clock / 64   (DMG, SGB2, CGB Single Speed Mode:  65536 Hz, SGB1:  ~67110 Hz, CGB Double Speed Mode: 131072 Hz)
-		timerUpdate = checkBit(prevDiv, t.DIV, 5)
-	case 0b11:
-		// 11: CPU Clock / 256  (DMG, SGB2, CGB Single Speed Mode:  16384 Hz, SGB1:  ~16780 Hz, CGB Double Speed Mode:  32768 Hz)
-		timerUpdate = checkBit(prevDiv, t.DIV, 7)
+	if t.reloading > 0 {
+		t.reloading--
 	}
 
-	// Bit  2   - Timer Enable
-	if timerUpdate && (t.TAC&(1<<2) == 1) {
-		// on overflow, set TIMA to TMA and request interrupt
-		if t.TIMA == 0xFF {
+	if t.overflow > 0 {
+		t.overflow--
+		if t.overflow == 0 {
+			// on overflow, set TIMA to TMA and request interrupt
 			t.TIMA = t.TMA
 			t.OnInterrupt()
-		} else {
-			t.TIMA++
+			t.reloading = reloadDelay
 		}
 	}
+
+	t.update(func() { t.DIV++ })
 }
 
-// checkBit checks if the bit is set in the previous value and not set in the current value
-func checkBit(prev, current uint16, bit byte) bool {
-	return (prev&(1<<bit) == 1) && (current&(1<<bit) == 0)
+// signal is the selected DIV bit ANDed with the enable bit, TIMA is clocked by this signal falling
+func (t *Timer) signal() bool {
+	return t.TAC&tacEnable != 0 && t.DIV&(1<<tacBits[t.TAC&0b11]) != 0
+}
+
+// update applies a change to DIV or TAC, incrementing TIMA if it causes a falling edge.
+// Since it's an edge detector, resetting DIV or changing TAC can increment TIMA early
+func (t *Timer) update(change func()) {
+	prev, prevDIV := t.signal(), t.DIV
+	change()
+
+	if prev && !t.signal() {
+		t.increment()
+	}
+
+	if prevDIV&frameSequencerBit != 0 && t.DIV&frameSequencerBit == 0 && t.OnFrameSequencer != nil {
+		t.OnFrameSequencer()
+	}
+}
+
+func (t *Timer) increment() {
+	t.TIMA++
+	if t.TIMA == 0x00 {
+		t.overflow = reloadDelay
+	}
 }
 
 func (t *Timer) Read(address uint16) byte {
@@ -76,7 +112,8 @@ func (t *Timer) Read(address uint16) byte {
 	case TMA_ADDRESS:
 		return t.TMA
 	case TAC_ADDRESS:
-		return t.TAC
+		// upper bits are unused and read as 1
+		return t.TAC | 0xF8
 	default:
 		panic(errs.NewReadError(address, "timer"))
 	}
@@ -86,14 +123,52 @@ func (t *Timer) Write(address uint16, data byte) {
 	switch address {
 	case DIV_ADDRESS:
 		// https://gbdev.io/pandocs/Timer_and_Divider_Registers.html?search=#ff04--div-divider-register
-		t.DIV = 0x0000
+		t.update(func() { t.DIV = 0x0000 })
 	case TIMA_ADDRESS:
+		// writes in the cycle TMA is loaded are ignored
+		if t.reloading > 0 {
+			return
+		}
+		// writes in the cycle after an overflow cancel the reload and interrupt
+		t.overflow = 0
 		t.TIMA = data
 	case TMA_ADDRESS:
 		t.TMA = data
+		// writes in the cycle TMA is loaded are also loaded into TIMA
+		if t.reloading > 0 {
+			t.TIMA = data
+		}
 	case TAC_ADDRESS:
-		t.TAC = data
+		t.update(func() { t.TAC = data & 0b111 })
 	default:
 		panic(errs.NewWriteError(address, "timer"))
 	}
 }
+
+// MarshalBinary encodes the timer's registers and internal state for save states
+func (t *Timer) MarshalBinary() ([]byte, error) {
+	return []byte{
+		bits.Lo(t.DIV),
+		bits.Hi(t.DIV),
+		t.TIMA,
+		t.TMA,
+		t.TAC,
+		t.overflow,
+		t.reloading,
+	}, nil
+}
+
+// UnmarshalBinary restores the timer from MarshalBinary
+func (t *Timer) UnmarshalBinary(data []byte) error {
+	if len(data) != 7 {
+		return errs.NewInvalidStateError("timer", len(data))
+	}
+
+	t.DIV = bits.To16(data[1], data[0])
+	t.TIMA = data[2]
+	t.TMA = data[3]
+	t.TAC = data[4]
+	t.overflow = data[5]
+	t.reloading = data[6]
+	return nil
+}
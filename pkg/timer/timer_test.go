@@ -0,0 +1,148 @@
+package timer_test
+
+import (
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/timer"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTimer() (*timer.Timer, *int) {
+	interrupts := 0
+	t := timer.New(func() { interrupts++ })
+	t.DIV = 0
+	return t, &interrupts
+}
+
+func tick(t *timer.Timer, cycles int) {
+	for i := 0; i < cycles; i++ {
+		t.Tick()
+	}
+}
+
+func TestTimerFrequency(t *testing.T) {
+	cases := []struct {
+		tac    byte
+		period int
+	}{
+		{0b100, 1024},
+		{0b101, 16},
+		{0b110, 64},
+		{0b111, 256},
+	}
+
+	for _, tc := range cases {
+		tm, _ := newTimer()
+		tm.Write(timer.TAC_ADDRESS, tc.tac)
+
+		tick(tm, tc.period-1)
+		assert.Equal(t, byte(0), tm.TIMA, "TAC %03b", tc.tac)
+		tick(tm, 1)
+		assert.Equal(t, byte(1), tm.TIMA, "TAC %03b", tc.tac)
+		tick(tm, tc.period*9)
+		assert.Equal(t, byte(10), tm.TIMA, "TAC %03b", tc.tac)
+	}
+}
+
+func TestTimerDisabled(t *testing.T) {
+	tm, interrupts := newTimer()
+	tm.Write(timer.TAC_ADDRESS, 0b001)
+
+	tick(tm, 0x10000)
+	assert.Equal(t, byte(0), tm.TIMA)
+	assert.Equal(t, 0, *interrupts)
+}
+
+func TestTimerFallingEdge(t *testing.T) {
+	cases := []struct {
+		name  string
+		write func(*timer.Timer)
+		tima  byte
+	}{
+		{"DIV reset while bit is set", func(tm *timer.Timer) { tm.Write(timer.DIV_ADDRESS, 0xFF) }, 1},
+		{"disable while bit is set", func(tm *timer.Timer) { tm.Write(timer.TAC_ADDRESS, 0b001) }, 1},
+		{"select unset bit", func(tm *timer.Timer) { tm.Write(timer.TAC_ADDRESS, 0b110) }, 1},
+		{"select set bit", func(tm *timer.Timer) { tm.Write(timer.TAC_ADDRESS, 0b111) }, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tm, _ := newTimer()
+			tm.Write(timer.TAC_ADDRESS, 0b101)
+			// DIV bit 3 is set, bit 5 is unset and bit 7 is set
+			tm.DIV = 0b1000_1000
+
+			tc.write(tm)
+			assert.Equal(t, tc.tima, tm.TIMA)
+		})
+	}
+}
+
+func TestTimerOverflow(t *testing.T) {
+	tm, interrupts := newTimer()
+	tm.Write(timer.TMA_ADDRESS, 0x42)
+	tm.Write(timer.TAC_ADDRESS, 0b101)
+	tm.TIMA = 0xFF
+
+	tick(tm, 16)
+	assert.Equal(t, byte(0x00), tm.Read(timer.TIMA_ADDRESS))
+	assert.Equal(t, 0, *interrupts)
+
+	tick(tm, 4)
+	assert.Equal(t, byte(0x42), tm.Read(timer.TIMA_ADDRESS))
+	assert.Equal(t, 1, *interrupts)
+}
+
+func TestTimerOverflowWrites(t *testing.T) {
+	cases := []struct {
+		name       string
+		delay      int
+		address    uint16
+		tima       byte
+		interrupts int
+	}{
+		{"TIMA write before reload cancels it", 0, timer.TIMA_ADDRESS, 0x10, 0},
+		{"TIMA write during reload is ignored", 4, timer.TIMA_ADDRESS, 0x42, 1},
+		{"TMA write during reload is loaded", 4, timer.TMA_ADDRESS, 0x10, 1},
+		{"TIMA write after reload", 8, timer.TIMA_ADDRESS, 0x10, 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tm, interrupts := newTimer()
+			tm.Write(timer.TMA_ADDRESS, 0x42)
+			tm.Write(timer.TAC_ADDRESS, 0b101)
+			tm.TIMA = 0xFF
+
+			tick(tm, 16+tc.delay)
+			tm.Write(tc.address, 0x10)
+			tick(tm, 4)
+
+			assert.Equal(t, tc.tima, tm.TIMA)
+			assert.Equal(t, tc.interrupts, *interrupts)
+		})
+	}
+}
+
+func TestTACRead(t *testing.T) {
+	tm, _ := newTimer()
+	tm.Write(timer.TAC_ADDRESS, 0x05)
+	assert.Equal(t, byte(0xFD), tm.Read(timer.TAC_ADDRESS))
+}
+
+func TestFrameSequencer(t *testing.T) {
+	tm, _ := newTimer()
+	clocks := 0
+	tm.OnFrameSequencer = func() { clocks++ }
+
+	// bit 4 of DIV falls at 512 Hz
+	tick(tm, 8192*2-1)
+	assert.Equal(t, 1, clocks)
+	tick(tm, 1)
+	assert.Equal(t, 2, clocks)
+
+	// resetting DIV while the bit is set clocks it early
+	tick(tm, 4096)
+	tm.Write(timer.DIV_ADDRESS, 0)
+	assert.Equal(t, 3, clocks)
+}
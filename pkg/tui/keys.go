@@ -0,0 +1,80 @@
+package tui
+
+import "github.com/robherley/go-gameboy/pkg/joypad"
+
+// Command is a key that controls the terminal instead of pressing a button
+type Command int
+
+const (
+	CommandNone Command = iota
+	CommandQuit
+	CommandPause
+	// CommandStep runs a single frame while paused
+	CommandStep
+)
+
+// Key is a key press read from the terminal, either a button or a command
+type Key struct {
+	Button  joypad.Button
+	Command Command
+}
+
+var letters = map[byte]Key{
+	'x':  {Button: joypad.A},
+	'X':  {Button: joypad.A},
+	'z':  {Button: joypad.B},
+	'Z':  {Button: joypad.B},
+	'\r': {Button: joypad.Start},
+	'\n': {Button: joypad.Start},
+	' ':  {Button: joypad.Select},
+	0x7F: {Button: joypad.Select}, // backspace
+	0x08: {Button: joypad.Select}, // ctrl+h, backspace on some terminals
+	'q':  {Command: CommandQuit},
+	'Q':  {Command: CommandQuit},
+	0x03: {Command: CommandQuit}, // ctrl+c, raw mode doesn't send SIGINT
+	'p':  {Command: CommandPause},
+	'P':  {Command: CommandPause},
+	'n':  {Command: CommandStep},
+	'N':  {Command: CommandStep},
+}
+
+// arrows are the final byte of the escape sequence for each arrow key, ESC [ A or ESC O A
+var arrows = map[byte]joypad.Button{
+	'A': joypad.Up,
+	'B': joypad.Down,
+	'C': joypad.Right,
+	'D': joypad.Left,
+}
+
+// ParseKeys parses the keys in input read from a terminal in raw mode, unknown keys are skipped.
+// Escape sequences split across reads are dropped, terminals write each key at once
+func ParseKeys(input []byte) []Key {
+	keys := []Key{}
+
+	for i := 0; i < len(input); i++ {
+		if input[i] != 0x1B {
+			if key, ok := letters[input[i]]; ok {
+				keys = append(keys, key)
+			}
+			continue
+		}
+
+		// ESC [ or ESC O start a sequence, which ends with a byte from 0x40-0x7E
+		if i+1 >= len(input) || (input[i+1] != '[' && input[i+1] != 'O') {
+			continue
+		}
+		i += 2
+		for i < len(input) && (input[i] < 0x40 || input[i] > 0x7E) {
+			i++
+		}
+		if i >= len(input) {
+			break
+		}
+
+		if button, ok := arrows[input[i]]; ok {
+			keys = append(keys, Key{Button: button})
+		}
+	}
+
+	return keys
+}
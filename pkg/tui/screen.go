@@ -0,0 +1,85 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+
+	"github.com/robherley/go-gameboy/pkg/ppu"
+)
+
+// The screen is drawn with upper half blocks, the foreground color is the top pixel and the
+// background color is the bottom pixel, so a character cell holds two pixels
+const (
+	halfBlock = "▀"
+	// ScreenColumns and ScreenRows are the size of the screen in character cells
+	ScreenColumns = ppu.Width
+	ScreenRows    = ppu.Height / 2
+)
+
+// Screen draws frames with ANSI escape codes and 24-bit color. Only the cells that changed since
+// the last frame are drawn, a full frame is ~500KiB which is too slow over SSH
+type Screen struct {
+	palette ppu.Palette
+	prev    ppu.Framebuffer
+	// valid is false until a frame has been drawn, or after the terminal was cleared
+	valid bool
+	buf   bytes.Buffer
+}
+
+func NewScreen(palette ppu.Palette) *Screen {
+	return &Screen{palette: palette}
+}
+
+// Invalidate draws every cell on the next frame, ie: after the terminal is cleared or resized
+func (s *Screen) Invalidate() {
+	s.valid = false
+}
+
+// Draw returns the escape codes that update the terminal to the frame, the screen is drawn at the
+// top left. The slice is reused by the next Draw
+func (s *Screen) Draw(fb *ppu.Framebuffer) []byte {
+	s.buf.Reset()
+
+	// the cursor and colors aren't known at the start of a frame
+	cursorRow, cursorColumn := -1, -1
+	var fg, bg byte = 0xFF, 0xFF
+
+	for row := 0; row < ScreenRows; row++ {
+		for column := 0; column < ScreenColumns; column++ {
+			top := (row*2)*ppu.Width + column
+			bottom := top + ppu.Width
+			if s.valid && fb[top] == s.prev[top] && fb[bottom] == s.prev[bottom] {
+				continue
+			}
+
+			if row != cursorRow || column != cursorColumn {
+				fmt.Fprintf(&s.buf, "\x1b[%d;%dH", row+1, column+1)
+			}
+			if fb[top] != fg {
+				fg = fb[top]
+				writeColor(&s.buf, 38, s.palette[fg])
+			}
+			if fb[bottom] != bg {
+				bg = fb[bottom]
+				writeColor(&s.buf, 48, s.palette[bg])
+			}
+			s.buf.WriteString(halfBlock)
+
+			cursorRow, cursorColumn = row, column+1
+		}
+	}
+
+	if s.buf.Len() > 0 {
+		s.buf.WriteString("\x1b[0m")
+	}
+
+	s.prev = *fb
+	s.valid = true
+	return s.buf.Bytes()
+}
+
+// writeColor sets the foreground (38) or background (48) color
+func writeColor(buf *bytes.Buffer, layer int, c color.RGBA) {
+	fmt.Fprintf(buf, "\x1b[%d;2;%d;%d;%dm", layer, c.R, c.G, c.B)
+}
@@ -0,0 +1,294 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robherley/go-gameboy/pkg/disasm"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+	"golang.org/x/term"
+)
+
+const (
+	// maxFPS limits how often the terminal is drawn, emulation still runs at full speed
+	maxFPS = 30
+	// holdFrames is how long a key press holds a button. Terminals don't send key releases, so
+	// buttons are let go when the key stops repeating
+	holdFrames = 10
+)
+
+type Options struct {
+	// Palette the screen is drawn with
+	Palette ppu.Palette
+}
+
+// Terminal plays the emulator in a terminal, for playing and debugging over SSH
+type Terminal struct {
+	emu    *emulator.Emulator
+	in     io.Reader
+	out    io.Writer
+	screen *Screen
+
+	// frames left that each button is held for
+	held    [8]int
+	pressed joypad.Button
+	paused  bool
+	// size of the terminal, when it changes the screen is redrawn
+	width, height int
+	// frames run and when the rate was last measured, for the fps in the sidebar
+	frames    int
+	measured  time.Time
+	fps       float64
+	lastDrawn time.Time
+}
+
+// New creates a terminal frontend reading keys from in and drawing to out. If in is a terminal it's
+// put in raw mode while running
+func New(emu *emulator.Emulator, in io.Reader, out io.Writer, opts Options) *Terminal {
+	return &Terminal{
+		emu:    emu,
+		in:     in,
+		out:    out,
+		screen: NewScreen(opts.Palette),
+	}
+}
+
+// Run the emulator in real time until q is pressed, ctx is done or the emulator faults
+func (t *Terminal) Run(ctx context.Context) error {
+	restore, err := t.setup()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	keys := make(chan []Key, 16)
+	// the reader blocks until input arrives, it's left behind when Run returns
+	go t.read(keys)
+
+	emu := t.emu
+	emu.Pacer.Reset()
+	t.measured = time.Now()
+	if err := t.draw(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		step := false
+	drain:
+		for {
+			select {
+			case pressed := <-keys:
+				for _, key := range pressed {
+					switch key.Command {
+					case CommandQuit:
+						return nil
+					case CommandPause:
+						t.paused = !t.paused
+						emu.Pacer.Reset()
+					case CommandStep:
+						step = true
+					default:
+						t.press(key.Button)
+					}
+				}
+			default:
+				break drain
+			}
+		}
+
+		if t.paused && !step {
+			if err := t.draw(); err != nil {
+				return err
+			}
+			time.Sleep(time.Second / maxFPS)
+			continue
+		}
+
+		t.release()
+		if err := emu.RunFrame(); err != nil {
+			return err
+		}
+		t.frames++
+
+		if !emu.Pacer.Wait() && !step {
+			continue
+		}
+		if emu.OnFrame != nil {
+			emu.OnFrame()
+		}
+		if step || time.Since(t.lastDrawn) >= time.Second/maxFPS {
+			if err := t.draw(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// setup puts the terminal in raw mode and switches to the alternate screen, restore undoes it
+func (t *Terminal) setup() (restore func(), err error) {
+	restoreMode := func() {}
+	if f, ok := t.in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		state, err := term.MakeRaw(int(f.Fd()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to put the terminal in raw mode: %w", err)
+		}
+		restoreMode = func() {
+			term.Restore(int(f.Fd()), state)
+		}
+	}
+
+	// alternate screen, hide the cursor, clear
+	fmt.Fprint(t.out, "\x1b[?1049h\x1b[?25l\x1b[2J")
+
+	return func() {
+		fmt.Fprint(t.out, "\x1b[0m\x1b[?25h\x1b[?1049l")
+		restoreMode()
+	}, nil
+}
+
+func (t *Terminal) read(keys chan<- []Key) {
+	buf := make([]byte, 64)
+	for {
+		n, err := t.in.Read(buf)
+		if n > 0 {
+			keys <- ParseKeys(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// press holds the button down, or keeps holding it when the key repeats
+func (t *Terminal) press(button joypad.Button) {
+	for i := range t.held {
+		if button&(1<<i) != 0 {
+			t.held[i] = holdFrames
+		}
+	}
+	t.update()
+}
+
+// release counts down the held buttons before a frame, letting go of the ones that ran out
+func (t *Terminal) release() {
+	for i := range t.held {
+		if t.held[i] > 0 {
+			t.held[i]--
+		}
+	}
+	t.update()
+}
+
+func (t *Terminal) update() {
+	pressed := joypad.Button(0)
+	for i, frames := range t.held {
+		if frames > 0 {
+			pressed |= 1 << i
+		}
+	}
+
+	if pressed != t.pressed {
+		t.emu.Joypad.SetPressed(pressed)
+		t.pressed = pressed
+	}
+}
+
+// draw updates the screen and the sidebar
+func (t *Terminal) draw() error {
+	t.lastDrawn = time.Now()
+
+	if f, ok := t.out.(*os.File); ok {
+		if width, height, err := term.GetSize(int(f.Fd())); err == nil && (width != t.width || height != t.height) {
+			t.width, t.height = width, height
+			// everything moves around when a terminal is resized
+			if _, err := fmt.Fprint(t.out, "\x1b[2J"); err != nil {
+				return err
+			}
+			t.screen.Invalidate()
+		}
+	}
+
+	if elapsed := time.Since(t.measured); elapsed >= time.Second {
+		t.fps = float64(t.frames) / elapsed.Seconds()
+		t.frames = 0
+		t.measured = time.Now()
+	}
+
+	var b strings.Builder
+	b.Write(t.screen.Draw(t.emu.Framebuffer()))
+	for i, line := range t.sidebar() {
+		// past the screen, erasing what's left of the last line
+		fmt.Fprintf(&b, "\x1b[%d;%dH%s\x1b[K", i+1, ScreenColumns+3, line)
+	}
+
+	_, err := io.WriteString(t.out, b.String())
+	return err
+}
+
+// sidebar lists the registers and what the emulator is doing
+func (t *Terminal) sidebar() []string {
+	emu := t.emu
+	r := emu.CPU.Registers
+	state := "running"
+	switch {
+	case t.paused:
+		state = "paused"
+	case emu.CPU.Locked:
+		state = "locked"
+	case emu.CPU.Halted:
+		state = "halted"
+	}
+
+	flags := []byte("----")
+	for i, flag := range "ZNHC" {
+		if r.F&(0x80>>i) != 0 {
+			flags[i] = byte(flag)
+		}
+	}
+	ime := 0
+	if emu.CPU.Interrupt.MasterEnabled {
+		ime = 1
+	}
+
+	next := "pc is outside of rom"
+	if r.PC < 0x8000 {
+		line := disasm.Decode(emu.Cartridge, disasm.BankFor(r.PC, emu.Cartridge.ROMBank()), r.PC)
+		next = line.Instruction()
+	}
+
+	return []string{
+		"go-gameboy",
+		fmt.Sprintf("frame %d, %.1f fps", emu.Frame(), t.fps),
+		state,
+		"",
+		fmt.Sprintf("AF %04X  BC %04X", r.GetAF(), r.GetBC()),
+		fmt.Sprintf("DE %04X  HL %04X", r.GetDE(), r.GetHL()),
+		fmt.Sprintf("SP %04X  PC %04X", r.SP, r.PC),
+		fmt.Sprintf("flags %s  IME %d", flags, ime),
+		fmt.Sprintf("IE %02X  IF %02X", emu.CPU.Interrupt.Enable, emu.CPU.Interrupt.Flag),
+		fmt.Sprintf("LCDC %02X  STAT %02X", emu.PPU.LCDC, emu.PPU.Read(ppu.STAT_ADDRESS)),
+		fmt.Sprintf("LY %02X  LYC %02X", emu.PPU.LY, emu.PPU.LYC),
+		fmt.Sprintf("rom bank %d", emu.Cartridge.ROMBank()),
+		fmt.Sprintf("buttons %s", t.pressed),
+		"",
+		"> " + next,
+		"",
+		"arrows  d-pad",
+		"x z     a b",
+		"enter   start",
+		"space   select",
+		"p pause, n step, q quit",
+	}
+}
@@ -0,0 +1,48 @@
+package tui_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+	"github.com/robherley/go-gameboy/pkg/tui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreen(t *testing.T) {
+	screen := tui.NewScreen(ppu.PaletteGray)
+	fb := &ppu.Framebuffer{}
+
+	first := screen.Draw(fb)
+	assert.Equal(t, tui.ScreenColumns*tui.ScreenRows, bytes.Count(first, []byte("▀")))
+
+	assert.Empty(t, screen.Draw(fb), "unchanged frames draw nothing")
+
+	fb[3*ppu.Width+10] = 3
+	changed := string(screen.Draw(fb))
+	assert.Equal(t, 1, bytes.Count([]byte(changed), []byte("▀")))
+	assert.Contains(t, changed, "\x1b[2;11H", "the bottom half of row 2")
+
+	screen.Invalidate()
+	assert.Equal(t, tui.ScreenColumns*tui.ScreenRows, bytes.Count(screen.Draw(fb), []byte("▀")))
+}
+
+func TestParseKeys(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		want  []tui.Key
+	}{
+		{"letters", "xz\r ", []tui.Key{{Button: joypad.A}, {Button: joypad.B}, {Button: joypad.Start}, {Button: joypad.Select}}},
+		{"arrows", "\x1b[A\x1b[B\x1bOC\x1b[1;2D", []tui.Key{{Button: joypad.Up}, {Button: joypad.Down}, {Button: joypad.Right}, {Button: joypad.Left}}},
+		{"commands", "pnq\x03", []tui.Key{{Command: tui.CommandPause}, {Command: tui.CommandStep}, {Command: tui.CommandQuit}, {Command: tui.CommandQuit}}},
+		{"unknown keys", "k\x1b[H\x1b", []tui.Key{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tui.ParseKeys([]byte(tc.input)))
+		})
+	}
+}
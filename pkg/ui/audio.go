@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"encoding/binary"
+
+	"github.com/robherley/go-gameboy/pkg/apu"
+	"github.com/robherley/go-gameboy/pkg/audio"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	// bytes in a 16-bit stereo sample
+	bytesPerSample = 4
+	// audioLatency is how much sound is kept queued for the sound card, in seconds. The queue's
+	// capacity is twice that, so the rate control has room either side
+	audioLatency = 0.05
+)
+
+// sdlAudio queues samples for the sound card. The emulator's clock and the sound card's drift
+// apart, so it asks for samples slightly faster or slower to keep the queue from running dry
+// (crackling) or growing (lagging behind the picture)
+type sdlAudio struct {
+	device sdl.AudioDeviceID
+	rate   int
+	// capacity of the queue in samples
+	capacity int
+	buf      []byte
+}
+
+// openAudio opens the default sound card
+func openAudio() (*sdlAudio, error) {
+	if err := sdl.InitSubSystem(sdl.INIT_AUDIO); err != nil {
+		return nil, err
+	}
+
+	desired := sdl.AudioSpec{
+		Freq:     audio.Rate48000,
+		Format:   sdl.AUDIO_S16LSB,
+		Channels: 2,
+		Samples:  1024,
+	}
+	// SDL converts if the sound card wants something else
+	device, err := sdl.OpenAudioDevice("", false, &desired, nil, 0)
+	if err != nil {
+		sdl.QuitSubSystem(sdl.INIT_AUDIO)
+		return nil, err
+	}
+	sdl.PauseAudioDevice(device, false)
+
+	return &sdlAudio{
+		device:   device,
+		rate:     audio.Rate48000,
+		capacity: int(2 * audioLatency * audio.Rate48000),
+	}, nil
+}
+
+// queued returns the number of samples waiting to be played
+func (a *sdlAudio) queued() int {
+	return int(sdl.GetQueuedAudioSize(a.device)) / bytesPerSample
+}
+
+func (a *sdlAudio) WriteSamples(samples []apu.Sample) error {
+	// running faster than real time, ie: unthrottled, would queue sound forever
+	if a.queued() > a.capacity {
+		return nil
+	}
+
+	if cap(a.buf) < len(samples)*bytesPerSample {
+		a.buf = make([]byte, len(samples)*bytesPerSample)
+	}
+	a.buf = a.buf[:len(samples)*bytesPerSample]
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(a.buf[i*4:], uint16(audio.PCM(s.Left)))
+		binary.LittleEndian.PutUint16(a.buf[i*4+2:], uint16(audio.PCM(s.Right)))
+	}
+
+	return sdl.QueueAudio(a.device, a.buf)
+}
+
+func (a *sdlAudio) RateAdjust() float64 {
+	return audio.DynamicRate(a.queued(), a.capacity)
+}
+
+func (a *sdlAudio) Close() {
+	sdl.CloseAudioDevice(a.device)
+	sdl.QuitSubSystem(sdl.INIT_AUDIO)
+}
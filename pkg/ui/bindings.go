@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Bindings map the joypad's buttons to keys and game controller buttons by name, so they can be
+// kept in a JSON file:
+//
+//	{
+//	  "keys": {"A": ["X", "Space"], "Start": ["Return"]},
+//	  "buttons": {"A": ["b"], "B": ["a"]},
+//	  "deadzone": 0.5
+//	}
+//
+// Buttons that aren't in the file keep their defaults.
+type Bindings struct {
+	// Keys are SDL key names, ie: "Z", "Return", "Right Shift"
+	// https://wiki.libsdl.org/SDL2/SDL_Keycode
+	Keys map[string][]string `json:"keys"`
+	// Buttons are SDL game controller button names, ie: "a", "start", "dpup"
+	// https://wiki.libsdl.org/SDL2/SDL_GameControllerGetStringForButton
+	Buttons map[string][]string `json:"buttons"`
+	// Deadzone is how far the left stick has to be pushed to press the d-pad, from 0 to 1
+	Deadzone float64 `json:"deadzone"`
+}
+
+// BindingsFile is the name of the bindings in the user's config directory
+const BindingsFile = "go-gameboy/bindings.json"
+
+// DefaultBindings puts A and B on the right and bottom face buttons, where they are on a Game Boy.
+// SDL maps controllers by position, so this is B and A on an Xbox controller, and circle and
+// cross on a PlayStation controller
+func DefaultBindings() Bindings {
+	return Bindings{
+		Keys: map[string][]string{
+			"Up":     {"Up"},
+			"Down":   {"Down"},
+			"Left":   {"Left"},
+			"Right":  {"Right"},
+			"A":      {"X"},
+			"B":      {"Z"},
+			"Start":  {"Return"},
+			"Select": {"Backspace", "Right Shift"},
+		},
+		Buttons: map[string][]string{
+			"Up":     {"dpup"},
+			"Down":   {"dpdown"},
+			"Left":   {"dpleft"},
+			"Right":  {"dpright"},
+			"A":      {"b"},
+			"B":      {"a", "x"},
+			"Start":  {"start"},
+			"Select": {"back"},
+		},
+		Deadzone: 0.4,
+	}
+}
+
+// DefaultBindingsPath returns where bindings are loaded from when a file isn't given
+func DefaultBindingsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, BindingsFile), nil
+}
+
+// LoadBindings reads bindings from a JSON file over the defaults. If path is empty, the file in the
+// user's config directory is used if it exists
+func LoadBindings(path string) (Bindings, error) {
+	b := DefaultBindings()
+
+	optional := path == ""
+	if optional {
+		var err error
+		if path, err = DefaultBindingsPath(); err != nil {
+			return b, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return b, fmt.Errorf("unable to read bindings: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &b); err != nil {
+		return b, fmt.Errorf("unable to parse bindings %s: %w", path, err)
+	}
+
+	if b.Deadzone < 0 || b.Deadzone >= 1 {
+		return b, fmt.Errorf("bindings deadzone must be from 0 to 1, got %g", b.Deadzone)
+	}
+
+	return b, nil
+}
+
+// keymap resolves the key names
+func (b Bindings) keymap() (map[sdl.Keycode]joypad.Button, error) {
+	keys := map[sdl.Keycode]joypad.Button{}
+	err := resolve(b.Keys, func(name string) bool {
+		return sdl.GetKeyFromName(name) != sdl.K_UNKNOWN
+	}, func(name string, button joypad.Button) {
+		keys[sdl.GetKeyFromName(name)] |= button
+	})
+
+	return keys, err
+}
+
+// buttonmap resolves the game controller button names
+func (b Bindings) buttonmap() (map[sdl.GameControllerButton]joypad.Button, error) {
+	buttons := map[sdl.GameControllerButton]joypad.Button{}
+	err := resolve(b.Buttons, func(name string) bool {
+		return sdl.GameControllerGetButtonFromString(name) != sdl.CONTROLLER_BUTTON_INVALID
+	}, func(name string, button joypad.Button) {
+		buttons[sdl.GameControllerGetButtonFromString(name)] |= button
+	})
+
+	return buttons, err
+}
+
+// resolve calls bind for every valid input name bound to a joypad button
+func resolve(bindings map[string][]string, valid func(name string) bool, bind func(name string, button joypad.Button)) error {
+	for buttonName, names := range bindings {
+		button, err := joypad.ParseButton(buttonName)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			if !valid(name) {
+				return fmt.Errorf("unknown input %q bound to %s", name, button)
+			}
+			bind(name, button)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"math"
+
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// controller is a connected game controller
+// https://wiki.libsdl.org/SDL2/CategoryGameController
+type controller struct {
+	gc *sdl.GameController
+	// buttons that are held down
+	buttons joypad.Button
+	// directions the left stick is pushed in, for the x and y axes
+	stick [2]joypad.Button
+}
+
+func (c *controller) pressed() joypad.Button {
+	return c.buttons | c.stick[0] | c.stick[1]
+}
+
+// axis presses the d-pad when the left stick is pushed past the deadzone
+func (c *controller) axis(axis uint8, value int16, deadzone int16) {
+	i, negative, positive := 0, joypad.Left, joypad.Right
+	switch axis {
+	case sdl.CONTROLLER_AXIS_LEFTX:
+	case sdl.CONTROLLER_AXIS_LEFTY:
+		i, negative, positive = 1, joypad.Up, joypad.Down
+	default:
+		return
+	}
+
+	c.stick[i] = 0
+	if value < -deadzone {
+		c.stick[i] = negative
+	} else if value > deadzone {
+		c.stick[i] = positive
+	}
+}
+
+// deadzone converts a deadzone from 0 to 1 to the range of an axis
+func deadzone(fraction float64) int16 {
+	return int16(fraction * math.MaxInt16)
+}
+
+// controllerEvent handles controllers being plugged in and out, and their input
+func (s *SDL) controllerEvent(event sdl.Event) {
+	switch e := event.(type) {
+	case *sdl.ControllerDeviceEvent:
+		switch e.Type {
+		case sdl.CONTROLLERDEVICEADDED:
+			// which is the device index when added, and the instance id afterwards
+			gc := sdl.GameControllerOpen(int(e.Which))
+			if gc == nil {
+				return
+			}
+
+			id := gc.Joystick().InstanceID()
+			if _, ok := s.controllers[id]; ok {
+				gc.Close()
+				return
+			}
+			s.controllers[id] = &controller{gc: gc}
+		case sdl.CONTROLLERDEVICEREMOVED:
+			if c, ok := s.controllers[e.Which]; ok {
+				c.gc.Close()
+				delete(s.controllers, e.Which)
+			}
+		}
+	case *sdl.ControllerButtonEvent:
+		c, ok := s.controllers[e.Which]
+		if !ok {
+			return
+		}
+
+		button := s.buttons[sdl.GameControllerButton(e.Button)]
+		if e.State == sdl.PRESSED {
+			c.buttons |= button
+		} else {
+			c.buttons &^= button
+		}
+	case *sdl.ControllerAxisEvent:
+		if c, ok := s.controllers[e.Which]; ok {
+			c.axis(e.Axis, e.Value, s.deadzone)
+		}
+	}
+}
+
+func (s *SDL) closeControllers() {
+	for id, c := range s.controllers {
+		c.gc.Close()
+		delete(s.controllers, id)
+	}
+}
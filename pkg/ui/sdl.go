@@ -1,46 +1,266 @@
 package ui
 
 import (
-	"errors"
+	"context"
+	"runtime"
 
+	"github.com/robherley/go-gameboy/pkg/apu"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/ppu"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
 const (
-	WindowTitle  = "go-gameboy"
-	WindowWidth  = 600
-	WindowHeight = 600
+	WindowTitle = "go-gameboy"
+	// DefaultScale is the window size as a multiple of the screen
+	DefaultScale = 4
 )
 
-func NewSDLWindow() error {
-	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
-		return err
+func init() {
+	// SDL has to be called from the main thread, which runs init
+	runtime.LockOSThread()
+}
+
+type Options struct {
+	// Palette the screen is drawn with
+	Palette ppu.Palette
+	// Scale is the initial window size as a multiple of the screen, DefaultScale if it's not set
+	Scale int
+	// Bindings map the keyboard and game controllers to the joypad, DefaultBindings if it's not set
+	Bindings *Bindings
+}
+
+// muteKeys toggle the sound channels
+var muteKeys = map[sdl.Keycode]apu.Channel{
+	sdl.K_1: apu.Square1,
+	sdl.K_2: apu.Square2,
+	sdl.K_3: apu.Wave,
+	sdl.K_4: apu.Noise,
+}
+
+// SDL shows the emulator in a window and feeds it the keyboard and game controllers
+type SDL struct {
+	emu      *emulator.Emulator
+	palette  ppu.Palette
+	window   *sdl.Window
+	renderer *sdl.Renderer
+	texture  *sdl.Texture
+	// pixels is the frame as RGBA, uploaded to the texture
+	pixels []byte
+
+	keys     map[sdl.Keycode]joypad.Button
+	buttons  map[sdl.GameControllerButton]joypad.Button
+	deadzone int16
+	// keyboard is the buttons held down on the keyboard, pressed is what the joypad was last set to
+	keyboard    joypad.Button
+	pressed     joypad.Button
+	controllers map[sdl.JoystickID]*controller
+
+	// audio is nil when there's no sound card, audioErr says why
+	audio       *sdlAudio
+	audioErr    error
+	removeAudio func()
+}
+
+// NewSDL opens a window for the emulator, it must be closed
+func NewSDL(emu *emulator.Emulator, opts Options) (*SDL, error) {
+	if opts.Scale == 0 {
+		opts.Scale = DefaultScale
+	}
+	bindings := DefaultBindings()
+	if opts.Bindings != nil {
+		bindings = *opts.Bindings
+	}
+
+	s := &SDL{
+		emu:         emu,
+		palette:     opts.Palette,
+		pixels:      make([]byte, ppu.Width*ppu.Height*4),
+		deadzone:    deadzone(bindings.Deadzone),
+		controllers: map[sdl.JoystickID]*controller{},
+	}
+
+	var err error
+	if s.keys, err = bindings.keymap(); err != nil {
+		return nil, err
+	}
+	if s.buttons, err = bindings.buttonmap(); err != nil {
+		return nil, err
 	}
-	defer sdl.Quit()
 
-	window, err := sdl.CreateWindow(WindowTitle, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
-		WindowWidth, WindowHeight, sdl.WINDOW_SHOWN)
+	// controllers that are already plugged in are added by events, like hot-plugged ones
+	if err := sdl.Init(sdl.INIT_VIDEO | sdl.INIT_GAMECONTROLLER); err != nil {
+		return nil, err
+	}
+
+	s.window, err = sdl.CreateWindow(WindowTitle, sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED,
+		int32(ppu.Width*opts.Scale), int32(ppu.Height*opts.Scale), sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	if err != nil {
-		return err
+		s.Close()
+		return nil, err
 	}
-	defer window.Destroy()
+	s.window.SetMinimumSize(ppu.Width, ppu.Height)
 
-	surface, err := window.GetSurface()
+	// the pacer keeps time, vsync would make frames wait twice
+	s.renderer, err = sdl.CreateRenderer(s.window, -1, sdl.RENDERER_ACCELERATED)
 	if err != nil {
-		return err
+		s.Close()
+		return nil, err
+	}
+
+	// the screen is scaled by whole pixels and letterboxed to keep its aspect ratio when resized
+	if err := s.renderer.SetLogicalSize(ppu.Width, ppu.Height); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err := s.renderer.SetIntegerScale(true); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	s.texture, err = s.renderer.CreateTexture(sdl.PIXELFORMAT_RGBA32, sdl.TEXTUREACCESS_STREAMING, ppu.Width, ppu.Height)
+	if err != nil {
+		s.Close()
+		return nil, err
 	}
-	surface.FillRect(nil, 0)
 
-	rect := sdl.Rect{X: 0, Y: 0, W: 200, H: 200}
-	surface.FillRect(&rect, 0x724CF9)
-	window.UpdateSurface()
+	// without a sound card the game still plays, silently
+	if s.audio, s.audioErr = openAudio(); s.audioErr == nil {
+		s.removeAudio = emu.AddAudio(s.audio, s.audio.rate)
+	}
+
+	return s, nil
+}
+
+// AudioError returns why there's no sound, or nil if there is
+func (s *SDL) AudioError() error {
+	return s.audioErr
+}
+
+// Close destroys the window and shuts down SDL
+func (s *SDL) Close() {
+	if s.audio != nil {
+		s.removeAudio()
+		s.audio.Close()
+	}
+	s.closeControllers()
+	if s.texture != nil {
+		s.texture.Destroy()
+	}
+	if s.renderer != nil {
+		s.renderer.Destroy()
+	}
+	if s.window != nil {
+		s.window.Destroy()
+	}
+	sdl.Quit()
+}
+
+// Run the emulator in real time until the window is closed, ctx is done or the emulator faults.
+// Closing the window isn't an error, so the caller can finish up (ie: write save states) as usual
+func (s *SDL) Run(ctx context.Context) error {
+	emu := s.emu
+	emu.Pacer.Reset()
 
 	for {
-		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			switch event.(type) {
-			case *sdl.QuitEvent:
-				return errors.New("quit")
-			}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
 		}
+
+		if quit := s.poll(); quit {
+			return nil
+		}
+
+		if err := emu.RunFrame(); err != nil {
+			return err
+		}
+
+		if !emu.Pacer.Wait() {
+			continue
+		}
+
+		if err := s.present(); err != nil {
+			return err
+		}
+		if emu.OnFrame != nil {
+			emu.OnFrame()
+		}
+	}
+}
+
+// poll handles the pending events, returning true if the window was closed
+func (s *SDL) poll() bool {
+	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+		switch e := event.(type) {
+		case *sdl.QuitEvent:
+			return true
+		case *sdl.KeyboardEvent:
+			s.key(e)
+		default:
+			s.controllerEvent(event)
+		}
+	}
+
+	// the same button can be held on the keyboard and controllers, it's released when none hold it
+	pressed := s.keyboard
+	for _, c := range s.controllers {
+		pressed |= c.pressed()
 	}
+	if pressed != s.pressed {
+		s.emu.Joypad.SetPressed(pressed)
+		s.pressed = pressed
+	}
+
+	return false
+}
+
+func (s *SDL) key(e *sdl.KeyboardEvent) {
+	if button, ok := s.keys[e.Keysym.Sym]; ok {
+		if e.Type == sdl.KEYDOWN {
+			s.keyboard |= button
+		} else {
+			s.keyboard &^= button
+		}
+		return
+	}
+
+	if e.Type != sdl.KEYDOWN || e.Repeat != 0 {
+		return
+	}
+
+	if c, ok := muteKeys[e.Keysym.Sym]; ok {
+		s.emu.APU.SetMuted(c, !s.emu.APU.Muted(c))
+	}
+}
+
+// present draws the last frame, scaled to the window
+func (s *SDL) present() error {
+	for i, shade := range s.emu.Framebuffer() {
+		c := s.palette[shade]
+		s.pixels[i*4] = c.R
+		s.pixels[i*4+1] = c.G
+		s.pixels[i*4+2] = c.B
+		s.pixels[i*4+3] = c.A
+	}
+
+	if err := s.texture.Update(nil, s.pixels, ppu.Width*4); err != nil {
+		return err
+	}
+
+	// the letterbox
+	if err := s.renderer.SetDrawColor(0, 0, 0, 0xFF); err != nil {
+		return err
+	}
+	if err := s.renderer.Clear(); err != nil {
+		return err
+	}
+	if err := s.renderer.Copy(s.texture, nil, nil); err != nil {
+		return err
+	}
+
+	s.renderer.Present()
+	return nil
 }
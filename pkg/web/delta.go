@@ -0,0 +1,72 @@
+package web
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/robherley/go-gameboy/pkg/ppu"
+)
+
+// Frames are sent as deltas of the palette indices that changed since the last frame. A delta is
+// a list of runs, each one is a little endian uint16 offset into the framebuffer, a uint16 length
+// and then length bytes of palette indices (0-3). The first frame is a single run of every pixel
+const runHeaderSize = 4
+
+// maxGap is how many unchanged pixels are sent to join two runs, it's cheaper than a new header
+const maxGap = runHeaderSize
+
+var ErrorInvalidDelta = errors.New("invalid frame delta")
+
+// EncodeDelta returns the runs of pixels that differ between prev and next, or all of them if
+// prev is nil. The delta is empty if nothing changed
+func EncodeDelta(prev, next *ppu.Framebuffer) []byte {
+	if prev == nil {
+		delta := make([]byte, runHeaderSize, runHeaderSize+len(next))
+		binary.LittleEndian.PutUint16(delta[2:], uint16(len(next)))
+		return append(delta, next[:]...)
+	}
+
+	delta := []byte{}
+	for i := 0; i < len(next); i++ {
+		if prev[i] == next[i] {
+			continue
+		}
+
+		// extend the run until there's a long enough stretch of unchanged pixels
+		start, end := i, i+1
+		for j := end; j < len(next) && j-end <= maxGap; j++ {
+			if prev[j] != next[j] {
+				end = j + 1
+			}
+		}
+
+		delta = append(delta, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint16(delta[len(delta)-4:], uint16(start))
+		binary.LittleEndian.PutUint16(delta[len(delta)-2:], uint16(end-start))
+		delta = append(delta, next[start:end]...)
+		i = end
+	}
+
+	return delta
+}
+
+// ApplyDelta writes the runs in delta to fb
+func ApplyDelta(fb *ppu.Framebuffer, delta []byte) error {
+	for len(delta) > 0 {
+		if len(delta) < runHeaderSize {
+			return ErrorInvalidDelta
+		}
+
+		offset := int(binary.LittleEndian.Uint16(delta))
+		length := int(binary.LittleEndian.Uint16(delta[2:]))
+		delta = delta[runHeaderSize:]
+		if offset+length > len(fb) || length > len(delta) {
+			return ErrorInvalidDelta
+		}
+
+		copy(fb[offset:], delta[:length])
+		delta = delta[length:]
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package web
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+)
+
+// The page is served at / and connects to /ws. The server starts with a JSON hello that has the
+// palette, then sends each frame as a binary delta (see EncodeDelta). The page sends JSON key
+// events back, ie: {"button": "A", "pressed": true}
+
+//go:embed index.html
+var page []byte
+
+type Options struct {
+	// Palette the page draws the screen with
+	Palette ppu.Palette
+}
+
+// Hello is the first message sent to a client
+type Hello struct {
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+	Palette []string `json:"palette"`
+}
+
+// KeyEvent is sent by a client when a button is pressed or let go
+type KeyEvent struct {
+	Button  string `json:"button"`
+	Pressed bool   `json:"pressed"`
+}
+
+// Server plays the emulator for any number of browsers, they all see the same game and their
+// buttons are combined
+type Server struct {
+	emu      *emulator.Emulator
+	palette  ppu.Palette
+	mux      *http.ServeMux
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	// frame is the last presented frame, new clients start with it
+	frame *ppu.Framebuffer
+}
+
+type client struct {
+	// frames has the latest frame that hasn't been sent yet, it's closed when the client is removed
+	frames  chan *ppu.Framebuffer
+	pressed joypad.Button
+}
+
+func New(emu *emulator.Emulator, opts Options) *Server {
+	s := &Server{
+		emu:     emu,
+		palette: opts.Palette,
+		mux:     http.NewServeMux(),
+		clients: map[*client]struct{}{},
+		frame:   &ppu.Framebuffer{},
+	}
+
+	s.mux.HandleFunc("/", s.index)
+	s.mux.HandleFunc("/ws", s.websocket)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Run the emulator in real time until ctx is done or it faults, connected clients are closed when
+// it returns
+func (s *Server) Run(ctx context.Context) error {
+	defer s.closeClients()
+
+	emu := s.emu
+	emu.Pacer.Reset()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if pressed := s.pressed(); pressed != emu.Joypad.Pressed() {
+			emu.Joypad.SetPressed(pressed)
+		}
+
+		if err := emu.RunFrame(); err != nil {
+			return err
+		}
+
+		if !emu.Pacer.Wait() {
+			continue
+		}
+
+		s.publish()
+		if emu.OnFrame != nil {
+			emu.OnFrame()
+		}
+	}
+}
+
+// pressed combines the buttons held by every client
+func (s *Server) pressed() joypad.Button {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pressed := joypad.Button(0)
+	for c := range s.clients {
+		pressed |= c.pressed
+	}
+	return pressed
+}
+
+// publish sends the frame to every client, replacing frames that slow clients haven't sent yet
+func (s *Server) publish() {
+	frame := *s.emu.Framebuffer()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.frame = &frame
+	for c := range s.clients {
+		select {
+		case <-c.frames:
+		default:
+		}
+		c.frames <- s.frame
+	}
+}
+
+func (s *Server) closeClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.clients {
+		s.remove(c)
+	}
+}
+
+// remove must be called with the lock held
+func (s *Server) remove(c *client) {
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		close(c.frames)
+	}
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+func (s *Server) websocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already replied with an error
+		return
+	}
+
+	hello := Hello{Width: ppu.Width, Height: ppu.Height}
+	for _, c := range s.palette {
+		hello.Palette = append(hello.Palette, fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B))
+	}
+	if err := conn.WriteJSON(hello); err != nil {
+		conn.Close()
+		return
+	}
+
+	c := &client{frames: make(chan *ppu.Framebuffer, 1)}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	c.frames <- s.frame
+	s.mu.Unlock()
+
+	go s.write(conn, c)
+	s.read(conn, c)
+}
+
+// write sends frames to the client until it's removed or the connection fails
+func (s *Server) write(conn *websocket.Conn, c *client) {
+	defer conn.Close()
+
+	var prev *ppu.Framebuffer
+	for frame := range c.frames {
+		delta := EncodeDelta(prev, frame)
+		prev = frame
+		if len(delta) == 0 {
+			continue
+		}
+
+		if err := conn.WriteMessage(websocket.BinaryMessage, delta); err != nil {
+			return
+		}
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "emulator stopped"))
+}
+
+// read applies the client's key events until the connection is closed
+func (s *Server) read(conn *websocket.Conn, c *client) {
+	defer func() {
+		s.mu.Lock()
+		s.remove(c)
+		s.mu.Unlock()
+	}()
+
+	for {
+		var event KeyEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return
+		}
+
+		button, err := joypad.ParseButton(event.Button)
+		if err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+		if event.Pressed {
+			c.pressed |= button
+		} else {
+			c.pressed &^= button
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,113 @@
+package web_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/robherley/go-gameboy/internal/testutil"
+	"github.com/robherley/go-gameboy/pkg/joypad"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+	"github.com/robherley/go-gameboy/pkg/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelta(t *testing.T) {
+	prev := &ppu.Framebuffer{}
+	next := *prev
+	next[10] = 3
+	next[12] = 2
+	next[5000] = 1
+
+	full := web.EncodeDelta(nil, &next)
+	assert.Len(t, full, 4+len(next))
+
+	assert.Empty(t, web.EncodeDelta(prev, prev))
+
+	delta := web.EncodeDelta(prev, &next)
+	// the close changes share a run, the far one gets its own
+	assert.Len(t, delta, (4+3)+(4+1))
+
+	for _, d := range [][]byte{full, delta} {
+		fb := *prev
+		require.NoError(t, web.ApplyDelta(&fb, d))
+		assert.Equal(t, next, fb)
+	}
+
+	assert.ErrorIs(t, web.ApplyDelta(prev, delta[:len(delta)-1]), web.ErrorInvalidDelta)
+}
+
+func TestServer(t *testing.T) {
+	emu := testutil.Emulator(t)
+
+	pressed := make(chan joypad.Button, 1)
+	emu.OnFrame = func() {
+		select {
+		case pressed <- emu.Joypad.Pressed():
+		default:
+		}
+	}
+
+	server := web.New(emu, web.Options{Palette: ppu.PaletteGray})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	res, err := ts.Client().Get(ts.URL)
+	require.NoError(t, err)
+	page, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "<canvas")
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var hello web.Hello
+	require.NoError(t, conn.ReadJSON(&hello))
+	assert.Equal(t, web.Hello{Width: ppu.Width, Height: ppu.Height, Palette: []string{"#ffffff", "#aaaaaa", "#555555", "#000000"}}, hello)
+
+	kind, full, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, kind)
+	assert.Len(t, full, 4+len(ppu.Framebuffer{}))
+
+	require.NoError(t, conn.WriteJSON(web.KeyEvent{Button: "start", Pressed: true}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- server.Run(ctx)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for buttons := joypad.Button(0); buttons != joypad.Start; {
+		select {
+		case buttons = <-pressed:
+		case <-deadline:
+			t.Fatal("start was never pressed")
+		}
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	// the client is closed when the emulator stops
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+			break
+		}
+	}
+
+	res, err = ts.Client().Get(ts.URL + "/nope")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
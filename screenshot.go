@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+)
+
+func screenshotCommand(args []string) error {
+	fs := newFlagSet("screenshot", "<path-to-rom>")
+	frames := fs.Int("frames", 600, "number of `frames` to run before the screenshot")
+	out := fs.String("out", "screenshot.png", "write the screenshot to `file`")
+	dumpDir := fs.String("dump-frames", "", "also write every frame to `dir` as numbered PNGs")
+	palette := fs.String("palette", "gray", fmt.Sprintf("colors to draw with (%s)", strings.Join(ppu.PaletteNames(), ", ")))
+	scale := fs.Int("scale", 1, "integer `factor` to scale the image up by")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	if *frames < 1 {
+		return fmt.Errorf("--frames must be at least 1")
+	}
+
+	dumper, err := newFrameDumper(*dumpDir, *palette, *scale)
+	if err != nil {
+		return err
+	}
+
+	cart, err := openCartridge(positional[0])
+	if err != nil {
+		return err
+	}
+
+	emu := emulator.New(cart)
+	if err := dumper.attach(emu); err != nil {
+		return err
+	}
+
+	if err := emu.RunFrames(context.Background(), *frames); err != nil {
+		return err
+	}
+	if dumper.err != nil {
+		return dumper.err
+	}
+
+	return dumper.write(emu, *out)
+}
+
+// frameDumper writes frames as PNGs
+type frameDumper struct {
+	dir     string
+	palette ppu.Palette
+	scale   int
+	// err is the first error writing a frame, OnFrame can't return it
+	err error
+}
+
+func newFrameDumper(dir, palette string, scale int) (*frameDumper, error) {
+	p, err := ppu.PaletteByName(palette)
+	if err != nil {
+		return nil, err
+	}
+
+	if scale < 1 || scale > 16 {
+		return nil, fmt.Errorf("--scale must be between 1 and 16")
+	}
+
+	return &frameDumper{dir: dir, palette: p, scale: scale}, nil
+}
+
+// attach writes every presented frame to the directory, if there is one. The directory is only
+// created here, after everything else has been checked
+func (d *frameDumper) attach(emu *emulator.Emulator) error {
+	if d.dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return fmt.Errorf("unable to create frame directory: %w", err)
+	}
+
+	emu.OnFrame = func() {
+		if d.err != nil {
+			return
+		}
+
+		path := filepath.Join(d.dir, fmt.Sprintf("frame_%06d.png", emu.Frame()))
+		d.err = d.write(emu, path)
+	}
+	return nil
+}
+
+// write the emulator's current frame to path
+func (d *frameDumper) write(emu *emulator.Emulator, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create image: %w", err)
+	}
+
+	if err := png.Encode(f, emu.Framebuffer().Image(d.palette, d.scale)); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+
+	return f.Close()
+}
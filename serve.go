@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/robherley/go-gameboy/pkg/emulator"
+	"github.com/robherley/go-gameboy/pkg/ppu"
+	"github.com/robherley/go-gameboy/pkg/web"
+)
+
+func serveCommand(args []string) (err error) {
+	fs := newFlagSet("serve", "<path-to-rom>")
+	addr := fs.String("addr", "localhost:8080", "`address` to listen on, use :8080 to let other machines connect")
+	palette := fs.String("palette", "gray", fmt.Sprintf("colors to draw the screen with (%s)", strings.Join(ppu.PaletteNames(), ", ")))
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	colors, err := ppu.PaletteByName(*palette)
+	if err != nil {
+		return err
+	}
+
+	cart, err := openCartridge(positional[0])
+	if err != nil {
+		return err
+	}
+
+	// battery backed ram is kept next to the rom like when playing locally, it's written after the
+	// server has stopped
+	if cart.CartridgeType().HasBattery() {
+		path := batteryPath(positional[0])
+		if err := cart.LoadRAM(path); err != nil {
+			return err
+		}
+		defer func() {
+			if saveErr := cart.SaveRAM(path); err == nil {
+				err = saveErr
+			}
+		}()
+	}
+
+	emu := emulator.New(cart)
+	server := web.New(emu, web.Options{Palette: colors})
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	// run until interrupted, or the server fails
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	httpServer := &http.Server{Handler: server}
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+			cancel()
+		}
+	}()
+	defer httpServer.Close()
+
+	fmt.Printf("serving %s on http://%s\n", positional[0], listener.Addr())
+
+	err = server.Run(ctx)
+	if errors.Is(err, context.Canceled) {
+		select {
+		case err = <-serveErr:
+		default:
+			err = nil
+		}
+	}
+
+	return err
+}
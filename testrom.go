@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/robherley/go-gameboy/pkg/testrom"
+)
+
+type testSummary struct {
+	Passed  int              `json:"passed"`
+	Failed  int              `json:"failed"`
+	Results []testrom.Result `json:"results"`
+}
+
+func testCommand(args []string) error {
+	fs := newFlagSet("test", "<path-to-rom>...")
+	cycles := fs.Uint64("cycles", testrom.DefaultMaxCycles, "T-cycle budget for each rom before timing out")
+	out := fs.String("json", "", "write the JSON summary to `file` instead of stdout")
+	verbose := fs.Bool("v", false, "echo serial output to stderr")
+	suiteName := fs.String("suite", "auto", fmt.Sprintf("test `suite` the roms are from (%s), auto detects either", suitesString()))
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	suite, err := testrom.SuiteByName(*suiteName)
+	if err != nil {
+		return err
+	}
+
+	opts := testrom.Options{MaxCycles: *cycles, Suite: suite}
+	if *verbose {
+		opts.Serial = os.Stderr
+	}
+
+	summary := testSummary{Results: []testrom.Result{}}
+	for _, path := range positional {
+		result, err := testrom.RunFile(path, opts)
+		if err != nil {
+			result = testrom.Result{ROM: path, Status: testrom.StatusError, Error: err.Error()}
+		}
+
+		if result.Passed() {
+			summary.Passed++
+		} else {
+			summary.Failed++
+		}
+		summary.Results = append(summary.Results, result)
+
+		fmt.Fprintf(os.Stderr, "%-7s %s\n", result.Status, path)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(summary); err != nil {
+		return err
+	}
+
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d test roms did not pass", summary.Failed, len(summary.Results))
+	}
+
+	return nil
+}
+
+func suitesString() string {
+	names := []string{}
+	for _, suite := range testrom.Suites {
+		names = append(names, string(suite))
+	}
+	return strings.Join(names, ", ")
+}